@@ -0,0 +1,349 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: timetracker/v1/reports.proto
+
+package timetrackerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetWorkloadRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Tasks started at or after start_time, when set.
+	StartTime *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	// Tasks finished at or before end_time, when set.
+	EndTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	// Page size, 50 when not set.
+	Limit int32 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	// next_cursor of the previous page.
+	Cursor string `protobuf:"bytes,5,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// name, start_time or duration, optionally followed by :asc or :desc,
+	// duration:desc when not set.
+	Sort          string `protobuf:"bytes,6,opt,name=sort,proto3" json:"sort,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkloadRequest) Reset() {
+	*x = GetWorkloadRequest{}
+	mi := &file_timetracker_v1_reports_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkloadRequest) ProtoMessage() {}
+
+func (x *GetWorkloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_v1_reports_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkloadRequest.ProtoReflect.Descriptor instead.
+func (*GetWorkloadRequest) Descriptor() ([]byte, []int) {
+	return file_timetracker_v1_reports_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetWorkloadRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *GetWorkloadRequest) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *GetWorkloadRequest) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *GetWorkloadRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetWorkloadRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *GetWorkloadRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+type GetWorkloadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Tasks []*TaskTime            `protobuf:"bytes,1,rep,name=tasks,proto3" json:"tasks,omitempty"`
+	// Empty on the last page.
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	Total         int32  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWorkloadResponse) Reset() {
+	*x = GetWorkloadResponse{}
+	mi := &file_timetracker_v1_reports_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWorkloadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWorkloadResponse) ProtoMessage() {}
+
+func (x *GetWorkloadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_v1_reports_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWorkloadResponse.ProtoReflect.Descriptor instead.
+func (*GetWorkloadResponse) Descriptor() ([]byte, []int) {
+	return file_timetracker_v1_reports_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetWorkloadResponse) GetTasks() []*TaskTime {
+	if x != nil {
+		return x.Tasks
+	}
+	return nil
+}
+
+func (x *GetWorkloadResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+func (x *GetWorkloadResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+// TaskTime is a finished task with the time spent on it.
+type TaskTime struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,5,opt,name=duration,proto3" json:"duration,omitempty"`
+	Version       int32                  `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskTime) Reset() {
+	*x = TaskTime{}
+	mi := &file_timetracker_v1_reports_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskTime) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskTime) ProtoMessage() {}
+
+func (x *TaskTime) ProtoReflect() protoreflect.Message {
+	mi := &file_timetracker_v1_reports_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskTime.ProtoReflect.Descriptor instead.
+func (*TaskTime) Descriptor() ([]byte, []int) {
+	return file_timetracker_v1_reports_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TaskTime) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *TaskTime) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TaskTime) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *TaskTime) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *TaskTime) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *TaskTime) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+var File_timetracker_v1_reports_proto protoreflect.FileDescriptor
+
+const file_timetracker_v1_reports_proto_rawDesc = "" +
+	"\n" +
+	"\x1ctimetracker/v1/reports.proto\x12\x0etimetracker.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe1\x01\n" +
+	"\x12GetWorkloadRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\x129\n" +
+	"\n" +
+	"start_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\x12\x12\n" +
+	"\x04sort\x18\x06 \x01(\tR\x04sort\"|\n" +
+	"\x13GetWorkloadResponse\x12.\n" +
+	"\x05tasks\x18\x01 \x03(\v2\x18.timetracker.v1.TaskTimeR\x05tasks\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\"\xf1\x01\n" +
+	"\bTaskTime\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x129\n" +
+	"\n" +
+	"start_time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x125\n" +
+	"\bduration\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x18\n" +
+	"\aversion\x18\x06 \x01(\x05R\aversion2g\n" +
+	"\rReportService\x12V\n" +
+	"\vGetWorkload\x12\".timetracker.v1.GetWorkloadRequest\x1a#.timetracker.v1.GetWorkloadResponseB@Z>github.com/3XBAT/time-tracker/api/timetracker/v1;timetrackerv1b\x06proto3"
+
+var (
+	file_timetracker_v1_reports_proto_rawDescOnce sync.Once
+	file_timetracker_v1_reports_proto_rawDescData []byte
+)
+
+func file_timetracker_v1_reports_proto_rawDescGZIP() []byte {
+	file_timetracker_v1_reports_proto_rawDescOnce.Do(func() {
+		file_timetracker_v1_reports_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_timetracker_v1_reports_proto_rawDesc), len(file_timetracker_v1_reports_proto_rawDesc)))
+	})
+	return file_timetracker_v1_reports_proto_rawDescData
+}
+
+var file_timetracker_v1_reports_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_timetracker_v1_reports_proto_goTypes = []any{
+	(*GetWorkloadRequest)(nil),    // 0: timetracker.v1.GetWorkloadRequest
+	(*GetWorkloadResponse)(nil),   // 1: timetracker.v1.GetWorkloadResponse
+	(*TaskTime)(nil),              // 2: timetracker.v1.TaskTime
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 4: google.protobuf.Duration
+}
+var file_timetracker_v1_reports_proto_depIdxs = []int32{
+	3, // 0: timetracker.v1.GetWorkloadRequest.start_time:type_name -> google.protobuf.Timestamp
+	3, // 1: timetracker.v1.GetWorkloadRequest.end_time:type_name -> google.protobuf.Timestamp
+	2, // 2: timetracker.v1.GetWorkloadResponse.tasks:type_name -> timetracker.v1.TaskTime
+	3, // 3: timetracker.v1.TaskTime.start_time:type_name -> google.protobuf.Timestamp
+	3, // 4: timetracker.v1.TaskTime.end_time:type_name -> google.protobuf.Timestamp
+	4, // 5: timetracker.v1.TaskTime.duration:type_name -> google.protobuf.Duration
+	0, // 6: timetracker.v1.ReportService.GetWorkload:input_type -> timetracker.v1.GetWorkloadRequest
+	1, // 7: timetracker.v1.ReportService.GetWorkload:output_type -> timetracker.v1.GetWorkloadResponse
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_timetracker_v1_reports_proto_init() }
+func file_timetracker_v1_reports_proto_init() {
+	if File_timetracker_v1_reports_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_timetracker_v1_reports_proto_rawDesc), len(file_timetracker_v1_reports_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_timetracker_v1_reports_proto_goTypes,
+		DependencyIndexes: file_timetracker_v1_reports_proto_depIdxs,
+		MessageInfos:      file_timetracker_v1_reports_proto_msgTypes,
+	}.Build()
+	File_timetracker_v1_reports_proto = out.File
+	file_timetracker_v1_reports_proto_goTypes = nil
+	file_timetracker_v1_reports_proto_depIdxs = nil
+}
@@ -4,27 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/3XBAT/time-tracker/internal/api"
 	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/events"
+	"github.com/3XBAT/time-tracker/internal/grpcapi"
 	"github.com/3XBAT/time-tracker/internal/handlers"
+	"github.com/3XBAT/time-tracker/internal/health"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"github.com/3XBAT/time-tracker/internal/migrator"
+	"github.com/3XBAT/time-tracker/internal/outbox"
+	"github.com/3XBAT/time-tracker/internal/purge"
 	"github.com/3XBAT/time-tracker/internal/service"
 	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/3XBAT/time-tracker/internal/tracing"
+	"github.com/3XBAT/time-tracker/internal/webhook"
 	"github.com/3XBAT/time-tracker/server"
+	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
+	"google.golang.org/grpc"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
-
-	// Драйвер для выполнения миграций
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	// Драйвер для получения миграций из файлов
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-)
-
-const (
-	envLocal = "local"
-	envDev   = "dev"
-	envProd  = "prod"
 )
 
 // @title Time Tracker API
@@ -35,60 +39,156 @@ const (
 // @BasePath /
 
 func main() {
-	log := setupLogger(envLocal)
-
-	log.Info("starting application")
-
-	cfg := config.MustLoad()
-
-	m, err := migrate.New(
-		"file://migrations",
-		fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-			cfg.DB.Username,
-			cfg.DB.Password,
-			cfg.DB.Host,
-			cfg.DB.Port,
-			cfg.DB.DBName,
-			cfg.DB.SSLMode),
-	)
+	cfg, args := config.MustLoad(os.Args[1:])
+
+	log := setupLogger(cfg.Env)
+
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			log.Error("unknown command", slog.String("command", args[0]))
+			os.Exit(2)
+		}
+		if err := runMigrate(cfg, log, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Info("starting application", slog.String("env", cfg.Env))
+
+	if cfg.Env != config.EnvLocal {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("No migrations to apply")
-		}
+	db, err := storage.NewPostgresDB(context.Background(), cfg, log)
+	if err != nil {
+		log.Error("failed to connect to the database", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
 
-	db, err := storage.NewPostgresDB(cfg)
+	m, err := migrator.New(cfg.DB, log)
 	if err != nil {
-		log.Warn(err.Error())
+		log.Error("failed to create migrator", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	if cfg.DB.AutoMigrate {
+		if err := m.Up(); err != nil {
+			if !errors.Is(err, migrate.ErrNoChange) {
+				log.Error("failed to apply migrations", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+			log.Info("no migrations to apply")
+		}
 	}
 
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db.DB, cfg.DB.DBName)
+
 	dataStorage := storage.NewStorage(db)
-	services := service.NewService(log, dataStorage)
-	handler := handlers.NewHandler(services)
+	appMetrics.RegisterRunningTasks(func() (int, error) {
+		return dataStorage.TaskProvider.CountRunning(context.Background())
+	})
+
+	apiClient := api.NewApiClient(&cfg, appMetrics)
+	bus := events.NewBus(cfg.Events, log)
+	services := service.NewService(log, dataStorage, apiClient, bus, cfg.Idempotency)
+	checker := health.NewChecker(cfg.HTTP.ReadinessTimeout)
+	checker.Add("database", db.PingContext)
+	checker.Add("migrations", func(context.Context) error { return m.Check() })
+	checker.Add("people_info_api", apiClient.Ping)
+
+	handler := handlers.NewHandler(log, services, appMetrics, checker, cfg.Features, cfg.Events)
 
 	srv := new(server.Server)
 	go func() {
-		if err := srv.Run(cfg.Port, handler.InitRoutes()); err != nil {
-			log.Error("error occurred while running the server %s", err.Error())
+		if err := srv.Run(cfg.Port, handler.InitRoutes(), cfg.HTTP); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("error occurred while running the server", slog.String("error", err.Error()))
 		}
 	}()
 
-	log.Info("server started")
+	grpcSrv := grpcapi.NewServer(log, services)
+	go func() {
+		if err := grpcSrv.Run(cfg.GRPCPort); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Error("error occurred while running the grpc server", slog.String("error", err.Error()))
+		}
+	}()
+
+	log.Info("server started", slog.String("port", cfg.Port), slog.String("grpc_port", cfg.GRPCPort))
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(3)
+	go func() {
+		defer workers.Done()
+		purge.NewWorker(dataStorage, cfg.Purge, cfg.Idempotency, log).Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhook.NewDispatcher(dataStorage, cfg.Webhooks, appMetrics, log).Run(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		sinks := outbox.NewSinks(cfg.Outbox, dataStorage)
+		outbox.NewRelay(dataStorage, cfg.Outbox, sinks, appMetrics, log).Run(workersCtx)
+	}()
+	if slices.Contains(cfg.Outbox.Sinks, config.SinkBus) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			outbox.NewBusFeed(dataStorage, bus, cfg.Outbox, log).Run(workersCtx)
+		}()
+	} else {
+		log.Warn("the bus outbox sink is off, GET /api/v1/events streams no events", slog.Any("sinks", cfg.Outbox.Sinks))
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	if err := srv.ShutDown(context.Background()); err != nil {
-		log.Error("error occurred while shutting down server: %s", err.Error())
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	// event streams never go idle, they are ended first so that the HTTP
+	// server does not wait for them, the clients reconnect elsewhere
+	bus.Close()
+
+	// both servers drain their requests at the same time, within the same
+	// shutdown timeout
+	grpcDone := make(chan struct{})
+	go func() {
+		defer close(grpcDone)
+		if err := grpcSrv.ShutDown(ctx); err != nil {
+			log.Error("error occurred while shutting down grpc server", slog.String("error", err.Error()))
+		}
+	}()
+
+	if err := srv.ShutDown(ctx); err != nil {
+		log.Error("error occurred while shutting down server", slog.String("error", err.Error()))
+	}
+	<-grpcDone
+
+	// deliveries interrupted here are sent again once their claim expires,
+	// events being relayed are relayed again on the next start
+	stopWorkers()
+	workers.Wait()
+
+	if err := m.Close(); err != nil {
+		log.Error("error occurred while closing migrator", slog.String("error", err.Error()))
 	}
 
 	if err := db.Close(); err != nil {
-		log.Error("error occurred while closing db: %s", err.Error())
+		log.Error("error occurred while closing db", slog.String("error", err.Error()))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("error occurred while flushing traces", slog.String("error", err.Error()))
 	}
 
 }
@@ -98,14 +198,14 @@ func setupLogger(env string) *slog.Logger {
 
 	switch env {
 
-	case envLocal:
+	case config.EnvLocal:
 		log = slog.New(
 			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	case envDev:
+	case config.EnvDev:
 		log = slog.New(
 			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
 		)
-	case envProd:
+	case config.EnvProd:
 		log = slog.New(
 			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
 		)
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/migrator"
+	"github.com/golang-migrate/migrate/v4"
+	"log/slog"
+	"strconv"
+)
+
+const migrateUsage = `usage: time-tracker [flags] migrate <command>
+
+commands:
+  up          apply all pending migrations
+  down N      roll back the last N migrations
+  goto V      migrate up or down to version V
+  version     print the current schema version
+  force V     set the schema version to V without migrating (-1 for none)`
+
+// runMigrate executes the migrate subcommand with its arguments.
+func runMigrate(cfg config.Config, log *slog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	var action func(m *migrator.Migrator) error
+
+	switch cmd := args[0]; cmd {
+	case "up":
+		action = func(m *migrator.Migrator) error {
+			return ignoreNoChange(m.Up())
+		}
+	case "down":
+		n, err := intArg(args, cmd)
+		if err != nil {
+			return err
+		}
+		action = func(m *migrator.Migrator) error {
+			return m.Down(n)
+		}
+	case "goto":
+		v, err := intArg(args, cmd)
+		if err != nil {
+			return err
+		}
+		if v < 0 {
+			return fmt.Errorf("goto: version must not be negative, got %d", v)
+		}
+		action = func(m *migrator.Migrator) error {
+			return ignoreNoChange(m.Goto(uint(v)))
+		}
+	case "force":
+		v, err := intArg(args, cmd)
+		if err != nil {
+			return err
+		}
+		action = func(m *migrator.Migrator) error {
+			return m.Force(v)
+		}
+	case "version":
+		action = func(*migrator.Migrator) error { return nil }
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", cmd, migrateUsage)
+	}
+
+	m, err := migrator.New(cfg.DB, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := action(m); err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("version %d (dirty)\n", version)
+	} else {
+		fmt.Printf("version %d\n", version)
+	}
+	return nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+func intArg(args []string, cmd string) (int, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("%s: expected exactly one numeric argument\n\n%s", cmd, migrateUsage)
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid argument %q", cmd, args[1])
+	}
+	return n, nil
+}
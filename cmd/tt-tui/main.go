@@ -0,0 +1,45 @@
+// Command tt-tui is an interactive terminal dashboard for the time tracker:
+// it shows the running timer, the tasks finished today and the time spent
+// this week, and starts, stops and switches timers. It shares the config of
+// tt and the timer started from this machine.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/ttclient"
+	tea "github.com/charmbracelet/bubbletea"
+	"os"
+)
+
+func main() {
+	path := flag.String("config", os.Getenv("TT_CONFIG"), "path to the config file, config.yaml in the user config directory by default")
+	server := flag.String("server", "", "base URL of the API, e.g. http://localhost:8080")
+	user := flag.Int("user", 0, "ID of the user whose tasks are tracked")
+	flag.Parse()
+
+	cfg, err := ttclient.LoadConfig(*path)
+	if err != nil {
+		fail(err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			cfg.Server = *server
+		case "user":
+			cfg.UserID = *user
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		fail(err)
+	}
+
+	if _, err := tea.NewProgram(newModel(cfg), tea.WithAltScreen()).Run(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "tt-tui:", err)
+	os.Exit(1)
+}
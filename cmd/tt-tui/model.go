@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/ttclient"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// refreshInterval picks up the changes made elsewhere, e.g. with tt.
+	refreshInterval = time.Minute
+	requestTimeout  = 10 * time.Second
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	runningStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("10"))
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	dimStyle      = lipgloss.NewStyle().Faint(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+// tickMsg advances the running timer.
+type tickMsg time.Time
+
+// loadedMsg carries the state read from the API.
+type loadedMsg struct {
+	running *models.Task
+	today   []models.OutputTask
+	week    []models.OutputTask
+	err     error
+}
+
+// doneMsg reports the outcome of an action, the state is reloaded after it.
+type doneMsg struct {
+	status string
+	// deleted is the task that can be brought back with undo.
+	deleted int
+	err     error
+}
+
+type model struct {
+	client *ttclient.Client
+	cfg    ttclient.Config
+
+	running *models.Task
+	today   []models.OutputTask
+	week    []models.OutputTask
+	loaded  time.Time
+	now     time.Time
+
+	selected int
+	deleted  int
+	// naming is set while the name of a new task is typed, editing while
+	// the edited task is changed.
+	naming  bool
+	editing bool
+	edited  models.OutputTask
+	input   textinput.Model
+
+	busy   bool
+	status string
+	err    error
+}
+
+func newModel(cfg ttclient.Config) model {
+	input := textinput.New()
+
+	return model{
+		client: ttclient.New(cfg),
+		cfg:    cfg,
+		now:    time.Now(),
+		input:  input,
+		busy:   true,
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.load(), tick())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		m.now = time.Time(msg)
+		if !m.busy && m.now.Sub(m.loaded) >= refreshInterval {
+			m.busy = true
+			return m, tea.Batch(m.load(), tick())
+		}
+		return m, tick()
+
+	case loadedMsg:
+		m.busy = false
+		m.loaded = time.Now()
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.running, m.today, m.week = msg.running, msg.today, msg.week
+		m.selected = min(m.selected, max(len(m.today)-1, 0))
+		return m, nil
+
+	case doneMsg:
+		m.status, m.err = msg.status, msg.err
+		if msg.deleted != 0 {
+			m.deleted = msg.deleted
+		}
+		return m, m.load()
+
+	case tea.KeyMsg:
+		if m.naming || m.editing {
+			return m.updateInput(msg)
+		}
+		return m.updateKeys(msg)
+	}
+
+	return m, nil
+}
+
+// updateInput handles the keys while the name of a new task is typed or a
+// task is edited.
+func (m model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.naming, m.editing = false, false
+		m.input.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		if m.editing {
+			return m.submitEdit()
+		}
+		name := strings.TrimSpace(m.input.Value())
+		m.naming = false
+		m.input.Blur()
+		if name == "" {
+			return m, nil
+		}
+		return m.act(m.switchTo(name))
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(m.today)-1 {
+			m.selected++
+		}
+	case "r":
+		m.busy = true
+		return m, m.load()
+	case "n", "s":
+		m.naming = true
+		m.input.Reset()
+		m.input.Placeholder = "task name"
+		m.input.CharLimit = 255
+		return m, m.input.Focus()
+	case "e":
+		if task, ok := m.selectedTask(); ok {
+			m.editing, m.edited = true, task
+			m.input.Placeholder = "09:00-10:30 task name"
+			m.input.CharLimit = len("15:04-15:04 ") + 255
+			m.input.SetValue(fmt.Sprintf("%s-%s %s", task.StartTime.Local().Format("15:04"), task.EndTime.Local().Format("15:04"), task.Name))
+			m.input.CursorEnd()
+			return m, m.input.Focus()
+		}
+	case "enter":
+		if task, ok := m.selectedTask(); ok {
+			return m.act(m.switchTo(task.Name))
+		}
+	case "x", " ":
+		if m.running != nil {
+			return m.act(m.stop())
+		}
+	case "d", "delete":
+		if task, ok := m.selectedTask(); ok {
+			return m.act(m.delete(task))
+		}
+	case "u":
+		if m.deleted != 0 {
+			id := m.deleted
+			m.deleted = 0
+			return m.act(m.restore(id))
+		}
+	}
+
+	return m, nil
+}
+
+// submitEdit saves the changes made to the edited task, a line that can not
+// be read is reported and left to be corrected.
+func (m model) submitEdit() (tea.Model, tea.Cmd) {
+	patch, err := editPatch(m.edited, m.input.Value())
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	m.editing = false
+	m.input.Blur()
+	if patch.Name == nil && patch.StartTime == nil && patch.EndTime == nil {
+		m.status, m.err = "Nothing changed", nil
+		return m, nil
+	}
+	return m.act(m.edit(m.edited, patch))
+}
+
+// editPatch turns the edited line, e.g. "09:00-10:30 review", into the
+// changes it makes to the task. The times are taken on the day the task
+// started, an end before the start falls on the next day. Times left as
+// they were are not sent, so that their seconds are kept.
+func editPatch(task models.OutputTask, line string) (models.InputTaskPatch, error) {
+	var patch models.InputTaskPatch
+
+	period, name, _ := strings.Cut(strings.TrimSpace(line), " ")
+	name = strings.TrimSpace(name)
+	from, to, ok := strings.Cut(period, "-")
+	if !ok || name == "" {
+		return patch, errors.New("expected start-end and name, e.g. 09:00-10:30 review")
+	}
+
+	startedAt := task.StartTime.Local()
+	start, err := clockOn(startedAt, from)
+	if err != nil {
+		return patch, err
+	}
+	end, err := clockOn(startedAt, to)
+	if err != nil {
+		return patch, err
+	}
+	if end.Before(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	if name != task.Name {
+		patch.Name = &name
+	}
+	if !start.Equal(startedAt.Truncate(time.Minute)) {
+		patch.StartTime = &start
+	}
+	if !end.Equal(task.EndTime.Truncate(time.Minute)) {
+		patch.EndTime = &end
+	}
+	return patch, nil
+}
+
+// clockOn returns the time of day given as HH:MM on the day of t.
+func clockOn(t time.Time, clock string) (time.Time, error) {
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, c.Hour(), c.Minute(), 0, 0, t.Location()), nil
+}
+
+// act runs an action unless another one is still running.
+func (m model) act(cmd tea.Cmd) (tea.Model, tea.Cmd) {
+	if m.busy {
+		return m, nil
+	}
+	m.busy = true
+	m.status, m.err = "", nil
+	return m, cmd
+}
+
+func (m model) selectedTask() (models.OutputTask, bool) {
+	if m.selected < 0 || m.selected >= len(m.today) {
+		return models.OutputTask{}, false
+	}
+	return m.today[m.selected], true
+}
+
+func (m model) load() tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		var msg loadedMsg
+		now := time.Now()
+		today, week := ttclient.StartOfDay(now), ttclient.StartOfWeek(now)
+		if msg.running, msg.err = client.Running(ctx); msg.err != nil {
+			return msg
+		}
+		if msg.week, msg.err = client.FinishedTasks(ctx, &week, nil); msg.err != nil {
+			return msg
+		}
+		for _, task := range msg.week {
+			if !task.StartTime.Before(today) {
+				msg.today = append(msg.today, task)
+			}
+		}
+		// newest first
+		sort.Slice(msg.today, func(i, j int) bool {
+			return msg.today[i].StartTime.After(msg.today[j].StartTime)
+		})
+		return msg
+	}
+}
+
+// switchTo stops the running timer, if any, and starts a new one.
+func (m model) switchTo(name string) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		if _, err := client.Stop(ctx, 0); err != nil && !errors.Is(err, ttclient.ErrNoTimer) {
+			return doneMsg{err: err}
+		}
+		task, err := client.Start(ctx, name)
+		if err != nil {
+			return doneMsg{err: err}
+		}
+		return doneMsg{status: fmt.Sprintf("Started %q", task.Name)}
+	}
+}
+
+func (m model) stop() tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		task, err := client.Stop(ctx, 0)
+		if err != nil {
+			return doneMsg{err: err}
+		}
+		return doneMsg{status: fmt.Sprintf("Stopped %q after %s", task.Name, formatDuration(task.EndTime.Sub(task.StartTime)))}
+	}
+}
+
+func (m model) delete(task models.OutputTask) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		if err := client.Delete(ctx, task.Id); err != nil {
+			return doneMsg{err: err}
+		}
+		return doneMsg{status: fmt.Sprintf("Deleted %q, press u to undo", task.Name), deleted: task.Id}
+	}
+}
+
+func (m model) edit(task models.OutputTask, patch models.InputTaskPatch) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		// the version shown is required, a task changed elsewhere since is
+		// refused rather than overwritten
+		updated, err := client.Edit(ctx, task.Id, task.Version, patch)
+		if err != nil {
+			return doneMsg{err: err}
+		}
+		return doneMsg{status: fmt.Sprintf("Changed %q", updated.Name)}
+	}
+}
+
+func (m model) restore(id int) tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		if err := client.Restore(ctx, id); err != nil {
+			return doneMsg{err: err}
+		}
+		return doneMsg{status: "Restored"}
+	}
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Time Tracker") + dimStyle.Render(fmt.Sprintf("  user %d at %s", m.cfg.UserID, m.cfg.Server)) + "\n\n")
+
+	switch {
+	case m.naming:
+		b.WriteString("Start: " + m.input.View() + "\n")
+	case m.editing:
+		b.WriteString("Edit: " + m.input.View() + "\n")
+	case m.running != nil:
+		b.WriteString(runningStyle.Render(fmt.Sprintf("▶ %s  %s", m.running.Name, formatClock(m.now.Sub(m.running.StartTime)))))
+		b.WriteString(dimStyle.Render("  since "+m.running.StartTime.Local().Format("15:04")) + "\n")
+	default:
+		b.WriteString(dimStyle.Render("No timer running") + "\n")
+	}
+
+	b.WriteString("\n" + titleStyle.Render("Today") + "\n")
+	if len(m.today) == 0 {
+		b.WriteString(dimStyle.Render("  nothing finished yet") + "\n")
+	}
+	var todayTotal time.Duration
+	for i, task := range m.today {
+		d := task.EndTime.Sub(task.StartTime)
+		todayTotal += d
+		line := fmt.Sprintf("%s-%s  %7s  %s", task.StartTime.Local().Format("15:04"), task.EndTime.Local().Format("15:04"), formatDuration(d), task.Name)
+		if i == m.selected {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	if m.running != nil {
+		todayTotal += m.now.Sub(m.running.StartTime)
+	}
+	b.WriteString(dimStyle.Render(fmt.Sprintf("  total %s", formatDuration(todayTotal))) + "\n")
+
+	b.WriteString("\n" + titleStyle.Render("This week") + "\n")
+	totals := m.weekTotals()
+	if len(totals) == 0 {
+		b.WriteString(dimStyle.Render("  nothing tracked yet") + "\n")
+	}
+	for _, t := range totals {
+		b.WriteString(fmt.Sprintf("  %7s  %s\n", formatDuration(t.duration), t.name))
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n")
+	case m.busy:
+		b.WriteString(dimStyle.Render("…") + "\n")
+	case m.status != "":
+		b.WriteString(m.status + "\n")
+	default:
+		b.WriteString("\n")
+	}
+
+	switch {
+	case m.naming:
+		b.WriteString(dimStyle.Render("enter start (stops the running timer)  esc cancel"))
+	case m.editing:
+		b.WriteString(dimStyle.Render("enter save  esc cancel"))
+	default:
+		b.WriteString(dimStyle.Render("n new  enter continue selected  e edit  x stop  d delete  u undo  r refresh  q quit"))
+	}
+	return b.String()
+}
+
+type nameTotal struct {
+	name     string
+	duration time.Duration
+}
+
+// weekTotals sums the time spent this week per task name, the running
+// timer included, longest first.
+func (m model) weekTotals() []nameTotal {
+	byName := make(map[string]time.Duration)
+	for _, task := range m.week {
+		byName[task.Name] += task.EndTime.Sub(task.StartTime)
+	}
+	if m.running != nil {
+		byName[m.running.Name] += m.now.Sub(m.running.StartTime)
+	}
+
+	totals := make([]nameTotal, 0, len(byName))
+	for name, d := range byName {
+		totals = append(totals, nameTotal{name: name, duration: d})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].duration != totals[j].duration {
+			return totals[i].duration > totals[j].duration
+		}
+		return totals[i].name < totals[j].name
+	})
+	return totals
+}
+
+// formatDuration prints d as hours and minutes, e.g. 1h 05m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// formatClock prints d as a ticking clock, e.g. 01:05:09.
+func formatClock(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"testing"
+	"time"
+)
+
+func TestEditPatch(t *testing.T) {
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2024, 7, 15, hour, minute, second, 0, time.Local)
+	}
+	task := models.OutputTask{Id: 1, Name: "review", StartTime: at(9, 0, 12), EndTime: at(10, 30, 40), Version: 3}
+
+	tests := []struct {
+		line      string
+		name      string
+		start     *time.Time
+		end       *time.Time
+		expectErr bool
+	}{
+		{line: "09:00-10:30 review"},
+		{line: "  09:00-10:30   review  "},
+		{line: "09:00-10:30 code review", name: "code review"},
+		{line: "08:45-10:30 review", start: ptr(at(8, 45, 0))},
+		{line: "09:00-11:00 review", end: ptr(at(11, 0, 0))},
+		{line: "23:00-01:00 review", start: ptr(at(23, 0, 0)), end: ptr(at(1, 0, 0).AddDate(0, 0, 1))},
+		{line: "09:00-10:30", expectErr: true},
+		{line: "09:00 review", expectErr: true},
+		{line: "9-10:30 review", expectErr: true},
+		{line: "09:00-25:00 review", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			patch, err := editPatch(task, tt.line)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", patch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := deref(patch.Name); got != tt.name {
+				t.Errorf("name = %q, want %q", got, tt.name)
+			}
+			if !sameTime(patch.StartTime, tt.start) {
+				t.Errorf("start_time = %v, want %v", patch.StartTime, tt.start)
+			}
+			if !sameTime(patch.EndTime, tt.end) {
+				t.Errorf("end_time = %v, want %v", patch.EndTime, tt.end)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/ttclient"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+func startTimer(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	if err := a.parseFlags(fs, args); err != nil {
+		return err
+	}
+	name := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(name) == "" {
+		return errors.New("usage: tt start NAME")
+	}
+
+	task, err := a.client.Start(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return a.print(task, func(p *printer) {
+		p.line("Started #%d %q at %s", task.Id, task.Name, task.StartTime.Local().Format("15:04"))
+	})
+}
+
+func stopTimer(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ContinueOnError)
+	if err := a.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var id int
+	if fs.NArg() > 0 {
+		var err error
+		if id, err = parseID(fs.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	task, err := a.client.Stop(ctx, id)
+	if errors.Is(err, ttclient.ErrNoTimer) {
+		return fmt.Errorf("%w, pass the task id: tt stop ID", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.print(task, func(p *printer) {
+		p.line("Stopped #%d %q after %s", task.Id, task.Name, formatDuration(task.EndTime.Sub(task.StartTime)))
+	})
+}
+
+// statusOutput is the JSON output of status, Task is nil when no timer is running.
+type statusOutput struct {
+	Running bool         `json:"running"`
+	Task    *models.Task `json:"task"`
+	Elapsed float64      `json:"elapsed_seconds,omitempty"`
+}
+
+func showStatus(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	if err := a.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	task, err := a.client.Running(ctx)
+	if err != nil {
+		return err
+	}
+
+	status := statusOutput{Running: task != nil, Task: task}
+	if task != nil {
+		status.Elapsed = time.Since(task.StartTime).Round(time.Second).Seconds()
+	}
+
+	return a.print(status, func(p *printer) {
+		if task == nil {
+			p.line("No timer running")
+			return
+		}
+		p.line("Running #%d %q since %s (%s)", task.Id, task.Name,
+			task.StartTime.Local().Format("15:04"), formatDuration(time.Since(task.StartTime)))
+	})
+}
+
+func showLog(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	week := fs.Bool("week", false, "list the tasks of this week instead of today")
+	if err := a.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	from := ttclient.StartOfDay(time.Now())
+	if *week {
+		from = ttclient.StartOfWeek(from)
+	}
+
+	tasks, err := a.client.FinishedTasks(ctx, &from, nil)
+	if err != nil {
+		return err
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.Before(tasks[j].StartTime)
+	})
+
+	return a.print(tasks, func(p *printer) {
+		if len(tasks) == 0 {
+			p.line("No finished tasks since %s", from.Format(dateLayout))
+			return
+		}
+
+		var total time.Duration
+		p.row("DATE", "START", "END", "TIME", "TASK")
+		for _, t := range tasks {
+			d := t.EndTime.Sub(t.StartTime)
+			total += d
+			start, end := t.StartTime.Local(), t.EndTime.Local()
+			p.row(start.Format(dateLayout), start.Format("15:04"), end.Format("15:04"), formatDuration(d), t.Name)
+		}
+		p.row("", "", "", formatDuration(total), "total")
+	})
+}
+
+// reportLine is the time spent on the tasks with the same name.
+type reportLine struct {
+	Name     string  `json:"name"`
+	Tasks    int     `json:"tasks"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+type reportOutput struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Duration float64      `json:"duration_seconds"`
+	Tasks    []reportLine `json:"tasks"`
+}
+
+func showReport(ctx context.Context, a *app, args []string) error {
+	now := time.Now()
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fromFlag := fs.String("from", ttclient.StartOfDay(now).AddDate(0, 0, 1-now.Day()).Format(dateLayout), "first day, the start of this month by default")
+	toFlag := fs.String("to", now.Format(dateLayout), "last day, today by default")
+	if err := a.parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	from, err := time.ParseInLocation(dateLayout, *fromFlag, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -from, expected YYYY-MM-DD: %s", *fromFlag)
+	}
+	to, err := time.ParseInLocation(dateLayout, *toFlag, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -to, expected YYYY-MM-DD: %s", *toFlag)
+	}
+	if to.Before(from) {
+		return errors.New("-to must not be before -from")
+	}
+	// the last day is included
+	end := to.AddDate(0, 0, 1)
+
+	tasks, err := a.client.FinishedTasks(ctx, &from, &end)
+	if err != nil {
+		return err
+	}
+
+	report := reportOutput{From: *fromFlag, To: *toFlag, Tasks: []reportLine{}}
+	byName := make(map[string]int)
+	for _, t := range tasks {
+		d := t.EndTime.Sub(t.StartTime).Seconds()
+		i, ok := byName[t.Name]
+		if !ok {
+			i = len(report.Tasks)
+			byName[t.Name] = i
+			report.Tasks = append(report.Tasks, reportLine{Name: t.Name})
+		}
+		report.Tasks[i].Tasks++
+		report.Tasks[i].Duration += d
+		report.Duration += d
+	}
+	sort.SliceStable(report.Tasks, func(i, j int) bool {
+		return report.Tasks[i].Duration > report.Tasks[j].Duration
+	})
+
+	return a.print(report, func(p *printer) {
+		if len(report.Tasks) == 0 {
+			p.line("No finished tasks from %s to %s", report.From, report.To)
+			return
+		}
+
+		p.row("TASK", "COUNT", "TIME")
+		for _, line := range report.Tasks {
+			p.row(line.Name, strconv.Itoa(line.Tasks), formatDuration(seconds(line.Duration)))
+		}
+		p.row("total", "", formatDuration(seconds(report.Duration)))
+	})
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
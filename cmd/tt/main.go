@@ -0,0 +1,125 @@
+// Command tt starts and stops timers and reports the time spent on tasks
+// through the REST API of the time tracker.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/ttclient"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+)
+
+const usage = `usage: tt [flags] <command> [command flags] [args]
+
+commands:
+  start NAME                 start a timer for a new task
+  stop [ID]                  stop the running timer, or the task with the given ID
+  status                     show the running timer
+  log [-week]                list the tasks finished today, or this week
+  report [-from D] [-to D]   time spent per task name, dates as YYYY-MM-DD
+
+every command accepts -json to print JSON instead of text
+
+flags:`
+
+// errUsage is returned for invalid command lines, the usage is printed instead.
+var errUsage = errors.New("invalid usage")
+
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"start":  startTimer,
+	"stop":   stopTimer,
+	"status": showStatus,
+	"log":    showLog,
+	"report": showReport,
+}
+
+type app struct {
+	cfg    ttclient.Config
+	client *ttclient.Client
+	out    io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tt:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("tt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	path := fs.String("config", os.Getenv("TT_CONFIG"), "path to the config file, config.yaml in the user config directory by default")
+	server := fs.String("server", "", "base URL of the API, e.g. http://localhost:8080")
+	user := fs.Int("user", 0, "ID of the user whose tasks are tracked")
+	output := fs.String("o", "", "output format: human or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := ttclient.LoadConfig(*path)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			cfg.Server = *server
+		case "user":
+			cfg.UserID = *user
+		case "o":
+			cfg.Output = *output
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	a := &app{cfg: cfg, client: ttclient.New(cfg), out: stdout}
+	return cmd(ctx, a, fs.Args()[1:])
+}
+
+// parseFlags parses the flags of a command, -json switches the output to JSON.
+func (a *app) parseFlags(fs *flag.FlagSet, args []string) error {
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON {
+		a.cfg.Output = ttclient.OutputJSON
+	}
+	return nil
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid task id: %s", s)
+	}
+	return id, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/ttclient"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printer writes the human output, rows are aligned in columns.
+type printer struct {
+	w *tabwriter.Writer
+}
+
+func (p *printer) line(format string, args ...any) {
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
+
+func (p *printer) row(cells ...string) {
+	fmt.Fprintln(p.w, strings.Join(cells, "\t"))
+}
+
+// print writes v as JSON or, for the human output, calls human.
+func (a *app) print(v any, human func(p *printer)) error {
+	if a.cfg.Output == ttclient.OutputJSON {
+		return writeJSON(a.out, v)
+	}
+
+	p := &printer{w: tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)}
+	human(p)
+	return p.w.Flush()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatDuration prints d as hours and minutes, e.g. 1h 05m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -1,46 +1,97 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/3XBAT/time-tracker/internal/config"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"net/http"
+	"time"
+)
+
+var (
+	ErrPersonNotFound  = errors.New("person not found")
+	ErrInvalidPassport = errors.New("invalid passport")
+	ErrUnavailable     = errors.New("people info service unavailable")
 )
 
 type ApiClient struct {
-	config *config.Config
+	config  *config.Config
+	metrics *metrics.Metrics
+	client  *http.Client
 }
 
-func NewApiClient(config *config.Config) *ApiClient {
-	return &ApiClient{config: config}
+func NewApiClient(config *config.Config, metrics *metrics.Metrics) *ApiClient {
+	return &ApiClient{
+		config:  config,
+		metrics: metrics,
+		// otelhttp traces outgoing calls and propagates the trace context
+		// to the people info service.
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   config.API.Timeout,
+		},
+	}
 }
 
-func (api *ApiClient) UserInfo(passportNumber, passportSeries string) (*models.User, error) {
+func (api *ApiClient) UserInfo(ctx context.Context, passportNumber, passportSeries string) (user *models.User, err error) {
 	const op = "api.UserInfo"
+
+	defer func(start time.Time) {
+		api.metrics.ObserveAPICall("UserInfo", time.Since(start), err)
+	}(time.Now())
+
 	url := fmt.Sprintf("%s/info?passportSerie=%s&passportNumber=%s", api.config.API.ExternalURL, passportSeries, passportNumber)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusBadRequest {
-			return nil, fmt.Errorf("%s bad request: %s", op, resp.Status)
+			return nil, fmt.Errorf("%s: %w: %s", op, ErrInvalidPassport, resp.Status)
 		}
 		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("%s: %s: the server can not find the requested resource", op, resp.Status)
+			return nil, fmt.Errorf("%s: %w: %s", op, ErrPersonNotFound, resp.Status)
 		}
-		return nil, fmt.Errorf("%s failed: %s", op, resp.Status)
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrUnavailable, resp.Status)
 
 	}
-	var user models.User
+	user = new(models.User)
 
-	if err = json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if err = json.NewDecoder(resp.Body).Decode(user); err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
+	}
+
+	return user, nil
+}
+
+// Ping checks that the people info service accepts connections. Any HTTP
+// response counts as reachable, only transport failures are reported.
+func (api *ApiClient) Ping(ctx context.Context) error {
+	const op = "api.Ping"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, api.config.API.ExternalURL, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, ErrUnavailable, err)
 	}
+	resp.Body.Close()
 
-	return &user, nil
+	return nil
 }
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// SystemActor is recorded for changes made outside of a client request.
+const SystemActor = "system"
+
+// Origin identifies who made a change and in which request.
+type Origin struct {
+	Actor     string
+	RequestID string
+}
+
+type ctxKey struct{}
+
+// WithOrigin returns a copy of ctx carrying the origin of the changes made
+// while handling it.
+func WithOrigin(ctx context.Context, o Origin) context.Context {
+	return context.WithValue(ctx, ctxKey{}, o)
+}
+
+// OriginFrom returns the origin stored in ctx, changes without one are
+// attributed to SystemActor.
+func OriginFrom(ctx context.Context) Origin {
+	if o, ok := ctx.Value(ctxKey{}).(Origin); ok && o.Actor != "" {
+		return o
+	}
+	return Origin{Actor: SystemActor}
+}
+
+// Diff returns the JSON state of an entity before and after a change,
+// reduced to the fields that differ. A nil before or after stands for an
+// entity that did not exist, the other side is then kept in full.
+func Diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		for k, v := range b {
+			if w, ok := a[k]; ok && reflect.DeepEqual(v, w) {
+				delete(b, k)
+				delete(a, k)
+			}
+		}
+	}
+
+	bj, err := marshal(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	aj, err := marshal(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bj, aj, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]any)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func marshal(m map[string]any) (json.RawMessage, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
@@ -1,56 +1,348 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
+	"net/url"
 	"os"
-	"path/filepath"
+	"time"
 )
 
+const (
+	EnvLocal = "local"
+	EnvDev   = "dev"
+	EnvProd  = "prod"
+)
+
+// Config is resolved in layers, each overriding the previous one: defaults
+// from the env-default tags, the YAML file, environment variables (also
+// read from a .env file if present) and command-line flags.
 type Config struct {
-	Env  string    `yaml:"env" env-default:"local"`
-	Port string    `yaml:"port" envDefault:":8080"`
-	DB   DBConfig  `yaml:"db"`
-	API  APIConfig `yaml:"api"`
+	Env         string            `yaml:"env" env:"ENV" env-default:"local"`
+	Port        string            `yaml:"port" env:"PORT" env-default:":8080"`
+	GRPCPort    string            `yaml:"grpc_port" env:"GRPC_PORT" env-default:":9090"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	DB          DBConfig          `yaml:"db"`
+	API         APIConfig         `yaml:"api"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Features    FeaturesConfig    `yaml:"features"`
+	Purge       PurgeConfig       `yaml:"purge"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Events      EventsConfig      `yaml:"events"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Outbox      OutboxConfig      `yaml:"outbox"`
+}
+
+type HTTPConfig struct {
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" env-default:"10s"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" env-default:"60s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"10s"`
+	// ReadinessTimeout bounds the dependency checks of /readyz.
+	ReadinessTimeout time.Duration `yaml:"readiness_timeout" env:"HTTP_READINESS_TIMEOUT" env-default:"2s"`
 }
 
 type DBConfig struct {
-	Host     string `yaml:"host"`
-	Port     string `yaml:"port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	DBName   string `yaml:"dbname"`
-	SSLMode  string `yaml:"sslmode"`
+	Host            string        `yaml:"host" env:"DB_HOST" env-default:"localhost"`
+	Port            string        `yaml:"port" env:"DB_PORT" env-default:"5432"`
+	Username        string        `yaml:"username" env:"DB_USERNAME"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD"`
+	DBName          string        `yaml:"dbname" env:"DB_NAME"`
+	SSLMode         string        `yaml:"sslmode" env:"SSL_MODE" env-default:"disable"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" env-default:"10"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" env-default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+	// ConnectAttempts and ConnectBackoff control the startup retries, the
+	// backoff doubles after every failed attempt.
+	ConnectAttempts int           `yaml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS" env-default:"5"`
+	ConnectBackoff  time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF" env-default:"1s"`
+	// AutoMigrate applies pending migrations on startup, when disabled they
+	// are applied with the migrate subcommand.
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" env-default:"true"`
 }
 
 type APIConfig struct {
-	ExternalURL string `yaml:"api_url"`
+	ExternalURL string        `yaml:"api_url" env:"API_URL"`
+	Timeout     time.Duration `yaml:"timeout" env:"API_TIMEOUT" env-default:"5s"`
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"` // none, stdout, otlp
+	Endpoint    string `yaml:"endpoint" env:"OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"time-tracker"`
+}
+
+// PurgeConfig controls how long deleted users and tasks can be restored
+// before they are removed for good, and how often that is checked.
+type PurgeConfig struct {
+	Retention time.Duration `yaml:"retention" env:"PURGE_RETENTION" env-default:"720h"`
+	Interval  time.Duration `yaml:"interval" env:"PURGE_INTERVAL" env-default:"1h"`
+}
+
+// IdempotencyConfig controls how long the responses to requests made with an
+// Idempotency-Key are replayed to their retries.
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	// LockTimeout is how long a request may hold its key before a retry
+	// takes it over, e.g. after the instance serving it has crashed.
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT" env-default:"1m"`
+}
+
+// EventsConfig controls the stream of changes served at /api/v1/events.
+type EventsConfig struct {
+	// History is how many of the latest events are kept for the clients
+	// resuming the stream with Last-Event-ID.
+	History int `yaml:"history" env:"EVENTS_HISTORY" env-default:"1000"`
+	// Buffer is how many events may wait for a slow client before it is
+	// disconnected, it then resumes from the history.
+	Buffer    int           `yaml:"buffer" env:"EVENTS_BUFFER" env-default:"64"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" env-default:"15s"`
+}
+
+// WebhooksConfig controls the delivery of events to webhooks. A failed
+// delivery is retried after Backoff, doubled after every attempt up to
+// MaxBackoff, until it has been tried MaxAttempts times.
+type WebhooksConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" env-default:"1s"`
+	// BatchSize is how many deliveries are sent at once.
+	BatchSize   int           `yaml:"batch_size" env:"WEBHOOKS_BATCH_SIZE" env-default:"20"`
+	Timeout     time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"10s"`
+	MaxAttempts int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"10"`
+	Backoff     time.Duration `yaml:"backoff" env:"WEBHOOKS_BACKOFF" env-default:"10s"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF" env-default:"1h"`
+}
+
+const (
+	SinkBus      = "bus"
+	SinkWebhooks = "webhooks"
+	SinkFile     = "file"
+	SinkHTTP     = "http"
+)
+
+// OutboxConfig controls the relay of the events from the outbox to the
+// sinks: the event stream (bus), the webhooks, a file of JSON lines and an
+// HTTP endpoint. A sink that fails is retried after Backoff, doubled after
+// every failure up to MaxBackoff, the other sinks carry on meanwhile.
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" env-default:"500ms"`
+	// BatchSize is how many events are handed to a sink at once.
+	BatchSize  int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	Sinks      []string      `yaml:"sinks" env:"OUTBOX_SINKS" env-default:"bus,webhooks"`
+	Backoff    time.Duration `yaml:"backoff" env:"OUTBOX_BACKOFF" env-default:"1s"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF" env-default:"5m"`
+	// Lease is how long an instance keeps relaying to a sink after its last
+	// batch, before another instance may take the sink over. It must
+	// outlast the handing over of a batch.
+	Lease time.Duration `yaml:"lease" env:"OUTBOX_LEASE" env-default:"30s"`
+	// FilePath is the file the file sink appends the events to.
+	FilePath string `yaml:"file_path" env:"OUTBOX_FILE_PATH"`
+	// HTTPURL is where the http sink POSTs the events, as a JSON array.
+	HTTPURL     string        `yaml:"http_url" env:"OUTBOX_HTTP_URL"`
+	HTTPTimeout time.Duration `yaml:"http_timeout" env:"OUTBOX_HTTP_TIMEOUT" env-default:"10s"`
 }
 
-func MustLoad() Config {
-	absPath, err := filepath.Abs(".env/")
+// FeaturesConfig switches optional parts of the service on and off.
+type FeaturesConfig struct {
+	Swagger bool `yaml:"swagger" env:"FEATURE_SWAGGER" env-default:"true"`
+	Metrics bool `yaml:"metrics" env:"FEATURE_METRICS" env-default:"true"`
+}
 
+// MustLoad loads the configuration using the command-line args (without
+// the program name) and exits if it is missing or invalid. The positional
+// arguments left after the flags are returned along with it.
+func MustLoad(args []string) (Config, []string) {
+	cfg, rest, err := Load(args)
 	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+		log.Fatalf("failed to load config: %v", err)
 	}
+	return cfg, rest
+}
 
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		panic("config file does not exist: " + absPath)
+func Load(args []string) (Config, []string, error) {
+	const op = "config.Load"
+
+	var cfg Config
+
+	fs := flag.NewFlagSet("time-tracker", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_PATH"), "path to the YAML config file")
+	overrides := bindFlags(fs, &cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := godotenv.Load(absPath); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil, fmt.Errorf("%s: failed to read .env: %w", op, err)
 	}
 
-	var cfg Config
-	cfg.Port = os.Getenv("PORT")
-	cfg.DB.Host = os.Getenv("DB_HOST")
-	cfg.DB.Port = os.Getenv("DB_PORT")
-	cfg.DB.Username = os.Getenv("DB_USERNAME")
-	cfg.DB.Password = os.Getenv("DB_PASSWORD")
-	cfg.DB.DBName = os.Getenv("DB_NAME")
-	cfg.DB.SSLMode = os.Getenv("SSL_MODE")
-	cfg.API.ExternalURL = os.Getenv("API_URL")
-
-	return cfg
+	var err error
+	if *path != "" {
+		err = cleanenv.ReadConfig(*path, &cfg)
+	} else {
+		err = cleanenv.ReadEnv(&cfg)
+	}
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if apply, ok := overrides[f.Name]; ok {
+			apply()
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// bindFlags registers the flags that may override loaded settings. Flag
+// values are kept aside and applied only for flags set explicitly, so that
+// unset flags do not clobber the file and environment layers.
+func bindFlags(fs *flag.FlagSet, cfg *Config) map[string]func() {
+	overrides := make(map[string]func())
+
+	str := func(name, usage string, dst *string) {
+		v := fs.String(name, "", usage)
+		overrides[name] = func() { *dst = *v }
+	}
+	dur := func(name, usage string, dst *time.Duration) {
+		v := fs.Duration(name, 0, usage)
+		overrides[name] = func() { *dst = *v }
+	}
+
+	str("env", "environment: local, dev or prod", &cfg.Env)
+	str("port", "HTTP listen address, e.g. :8080", &cfg.Port)
+	str("grpc-port", "gRPC listen address, e.g. :9090", &cfg.GRPCPort)
+	str("db-host", "database host", &cfg.DB.Host)
+	str("db-port", "database port", &cfg.DB.Port)
+	str("db-name", "database name", &cfg.DB.DBName)
+	str("db-user", "database user", &cfg.DB.Username)
+	str("api-url", "people info API base URL", &cfg.API.ExternalURL)
+	str("tracing-exporter", "trace exporter: none, stdout or otlp", &cfg.Tracing.Exporter)
+	dur("http-write-timeout", "HTTP write timeout", &cfg.HTTP.WriteTimeout)
+	dur("api-timeout", "people info API request timeout", &cfg.API.Timeout)
+	dur("purge-retention", "how long deleted records can be restored", &cfg.Purge.Retention)
+
+	maxOpen := fs.Int("db-max-open-conns", 0, "maximum number of open database connections")
+	overrides["db-max-open-conns"] = func() { cfg.DB.MaxOpenConns = *maxOpen }
+
+	autoMigrate := fs.Bool("auto-migrate", true, "apply pending migrations on startup")
+	overrides["auto-migrate"] = func() { cfg.DB.AutoMigrate = *autoMigrate }
+
+	return overrides
+}
+
+// Validate reports the first setting that prevents the service from starting.
+func (c Config) Validate() error {
+	switch c.Env {
+	case EnvLocal, EnvDev, EnvProd:
+	default:
+		return fmt.Errorf("env must be one of %s, %s, %s, got %q", EnvLocal, EnvDev, EnvProd, c.Env)
+	}
+
+	if c.Port == "" || c.GRPCPort == "" {
+		return errors.New("port and grpc_port are required")
+	}
+	if c.Port == c.GRPCPort {
+		return errors.New("port and grpc_port must differ")
+	}
+
+	if c.DB.Host == "" || c.DB.Port == "" || c.DB.DBName == "" || c.DB.Username == "" {
+		return errors.New("db host, port, dbname and username are required")
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		return errors.New("db connection pool sizes must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		return errors.New("db max_idle_conns must not exceed max_open_conns")
+	}
+	if c.DB.ConnectAttempts < 1 {
+		return errors.New("db connect_attempts must be at least 1")
+	}
+
+	if u, err := url.Parse(c.API.ExternalURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("api_url must be an absolute URL, got %q", c.API.ExternalURL)
+	}
+
+	for name, d := range map[string]time.Duration{
+		"http read_timeout":        c.HTTP.ReadTimeout,
+		"http write_timeout":       c.HTTP.WriteTimeout,
+		"http shutdown_timeout":    c.HTTP.ShutdownTimeout,
+		"http readiness_timeout":   c.HTTP.ReadinessTimeout,
+		"db connect_backoff":       c.DB.ConnectBackoff,
+		"api timeout":              c.API.Timeout,
+		"purge retention":          c.Purge.Retention,
+		"purge interval":           c.Purge.Interval,
+		"idempotency ttl":          c.Idempotency.TTL,
+		"idempotency lock_timeout": c.Idempotency.LockTimeout,
+		"events heartbeat":         c.Events.Heartbeat,
+		"webhooks poll_interval":   c.Webhooks.PollInterval,
+		"webhooks timeout":         c.Webhooks.Timeout,
+		"webhooks backoff":         c.Webhooks.Backoff,
+		"webhooks max_backoff":     c.Webhooks.MaxBackoff,
+		"outbox poll_interval":     c.Outbox.PollInterval,
+		"outbox backoff":           c.Outbox.Backoff,
+		"outbox max_backoff":       c.Outbox.MaxBackoff,
+		"outbox http_timeout":      c.Outbox.HTTPTimeout,
+		"outbox lease":             c.Outbox.Lease,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+	}
+
+	if c.Events.History < 0 || c.Events.Buffer < 1 {
+		return errors.New("events history must not be negative and buffer must be at least 1")
+	}
+
+	if c.Webhooks.BatchSize < 1 || c.Webhooks.MaxAttempts < 1 {
+		return errors.New("webhooks batch_size and max_attempts must be at least 1")
+	}
+
+	if c.Outbox.BatchSize < 1 {
+		return errors.New("outbox batch_size must be at least 1")
+	}
+	if c.Outbox.Lease <= c.Outbox.HTTPTimeout {
+		return errors.New("outbox lease must be longer than http_timeout")
+	}
+	seen := make(map[string]bool)
+	for _, sink := range c.Outbox.Sinks {
+		switch sink {
+		case SinkBus, SinkWebhooks:
+		case SinkFile:
+			if c.Outbox.FilePath == "" {
+				return errors.New("outbox file_path is required for the file sink")
+			}
+		case SinkHTTP:
+			if u, err := url.Parse(c.Outbox.HTTPURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("outbox http_url must be an http or https URL for the http sink, got %q", c.Outbox.HTTPURL)
+			}
+		default:
+			return fmt.Errorf("unknown outbox sink %q", sink)
+		}
+		if seen[sink] {
+			return fmt.Errorf("outbox sink %q is listed twice", sink)
+		}
+		seen[sink] = true
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			return errors.New("tracing endpoint is required for the otlp exporter")
+		}
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", c.Tracing.Exporter)
+	}
+
+	return nil
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionErase   = "erase"
+
+	AuditEntityUser = "user"
+	AuditEntityTask = "task"
+)
+
+// AuditEvent records a change of a user or a task. Before and After hold
+// only the fields that changed, the missing side of a create or a delete
+// is null.
+type AuditEvent struct {
+	ID         int64           `json:"id" db:"id"`
+	OccurredAt time.Time       `json:"occurred_at" db:"occurred_at"`
+	Actor      string          `json:"actor" db:"actor"`
+	RequestID  *string         `json:"request_id,omitempty" db:"request_id"`
+	Action     string          `json:"action" db:"action"`
+	Entity     string          `json:"entity" db:"entity"`
+	EntityID   int             `json:"entity_id" db:"entity_id"`
+	Before     json.RawMessage `json:"before" db:"before_state" swaggertype:"object"`
+	After      json.RawMessage `json:"after" db:"after_state" swaggertype:"object"`
+}
+
+// AuditFilter selects audit events, newest first.
+type AuditFilter struct {
+	Actor    string     `json:"actor" form:"actor" binding:"omitempty,max=128"`
+	Action   string     `json:"action" form:"action" binding:"omitempty,oneof=create update delete restore erase"`
+	Entity   string     `json:"entity" form:"entity" binding:"omitempty,oneof=user task"`
+	EntityID int        `json:"entity_id" form:"entity_id" binding:"omitempty,gt=0"`
+	From     *time.Time `json:"from" form:"from"`
+	To       *time.Time `json:"to" form:"to"`
+	Limit    int        `json:"limit" form:"limit,default=50" binding:"min=1,max=100"`
+	Cursor   string     `json:"cursor" form:"cursor"`
+}
+
+// AuditPage is one page of audit events, NextCursor is empty on the last page.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	EventTaskStarted  = "task.started"
+	EventTaskStopped  = "task.stopped"
+	EventTaskUpdated  = "task.updated"
+	EventTaskDeleted  = "task.deleted"
+	EventTaskRestored = "task.restored"
+	EventUserCreated  = "user.created"
+	EventUserUpdated  = "user.updated"
+	EventUserDeleted  = "user.deleted"
+	EventUserRestored = "user.restored"
+)
+
+// Event announces a change of a task or a user. UserID is the user the
+// change concerns, Task or User holds the state of the entity after the
+// change, or before it for a delete. Events leave the personal data of
+// users out, see EventUser. ID is the position of the event in the
+// outbox, the event stream numbers the events it sends on its own.
+type Event struct {
+	ID         uint64     `json:"id,omitempty"`
+	Type       string     `json:"type"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	UserID     int        `json:"user_id"`
+	Task       *Task      `json:"task,omitempty"`
+	User       *EventUser `json:"user,omitempty"`
+}
+
+// EventUser is the state of a user carried by events. It has none of the
+// personal data: events end up where the erasure of a user can not reach
+// them, in files, in the history of the event stream and at the receivers
+// of webhooks, so consumers read the data from the API.
+type EventUser struct {
+	ID        int        `json:"id"`
+	Version   int        `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ErasedAt  *time.Time `json:"erased_at,omitempty"`
+}
+
+// Aggregate returns the entity the event is about, the events of an entity
+// are published in the order of its changes.
+func (e Event) Aggregate() (string, int) {
+	if e.Task != nil {
+		return AuditEntityTask, e.Task.Id
+	}
+	return AuditEntityUser, e.UserID
+}
+
+// EventFilter selects the events of a stream, empty fields match every
+// event. Events published after the one with the ID LastEventID are
+// replayed first, as far as they are still kept.
+type EventFilter struct {
+	UserIDs     []int    `json:"user_id" form:"user_id" binding:"omitempty,max=100,dive,gt=0"`
+	Types       []string `json:"type" form:"type" binding:"omitempty,dive,oneof=task.started task.stopped task.updated task.deleted task.restored user.created user.updated user.deleted user.restored"`
+	LastEventID uint64   `json:"-" form:"-"`
+}
+
+// Match reports whether the event is selected by the filter.
+func (f EventFilter) Match(e Event) bool {
+	return (len(f.UserIDs) == 0 || slices.Contains(f.UserIDs, e.UserID)) &&
+		(len(f.Types) == 0 || slices.Contains(f.Types, e.Type))
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey is a request made with an Idempotency-Key header. Once the
+// request completes it holds the response replayed to its retries, until
+// then StatusCode is 0. UserID is the user whose data the request carries,
+// its record is removed when the user is erased.
+type IdempotencyKey struct {
+	Actor       string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Header      map[string]string
+	Body        []byte
+	UserID      int
+	CreatedAt   time.Time
+}
@@ -3,36 +3,112 @@ package models
 import "time"
 
 type Task struct {
-	Id        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	Name      string    `json:"name" db:"name"`
-	StartTime time.Time `json:"start_time" db:"start_time"`
-	EndTime   time.Time `json:"end_time" db:"end_time"`
+	Id        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Name      string     `json:"name" db:"name"`
+	StartTime time.Time  `json:"start_time" db:"start_time"`
+	EndTime   *time.Time `json:"end_time" db:"end_time"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Version   int        `json:"version" db:"version"`
 }
 
+// InputTaskUpdate finishes a task whose version is still Version, taken
+// from the If-Match header. Zero matches any version. When UserID is zero
+// the task may belong to any user.
 type InputTaskUpdate struct {
-	Id     int `json:"id"`
-	UserID int `json:"user_id"`
+	Id      int `json:"id" binding:"required,gt=0"`
+	UserID  int `json:"user_id" binding:"required,gt=0"`
+	Version int `json:"-"`
 }
 
+// InputTaskPatch changes the given fields of a task whose version is still
+// Version, taken from the If-Match header. Zero matches any version. The
+// end time can only be changed once the task is finished.
+type InputTaskPatch struct {
+	Name      *string    `json:"name,omitempty" binding:"omitempty,notblank,max=255"`
+	StartTime *time.Time `json:"start_time,omitempty" binding:"omitempty,notfuture"`
+	EndTime   *time.Time `json:"end_time,omitempty" binding:"omitempty,notfuture"`
+	Version   int        `json:"-"`
+}
+
+// InputTask selects the finished tasks of a user, the period must not end
+// before it starts.
 type InputTask struct {
-	UserID      int        `json:"user_id" db:"user_id"`
-	StartPeriod *time.Time `json:"start_time" db:"start_time"`
-	EndPeriod   *time.Time `json:"end_time" db:"end_time"`
+	UserID      int        `json:"user_id" db:"user_id" form:"user_id" binding:"required,gt=0"`
+	StartPeriod *time.Time `json:"start_time" db:"start_time" form:"start_time"`
+	EndPeriod   *time.Time `json:"end_time" db:"end_time" form:"end_time"`
+	Limit       int        `json:"limit" form:"limit,default=50" binding:"min=1,max=100"`
+	Cursor      string     `json:"cursor" form:"cursor"`
+	Sort        string     `json:"sort" form:"sort" binding:"omitempty,sortby=name start_time duration"`
+}
+
+// TaskPeriod selects the tasks started within a period, either bound may
+// be left out.
+type TaskPeriod struct {
+	From *time.Time
+	To   *time.Time
 }
 
 type InputTaskCreate struct {
-	UserID      int        `json:"user_id"`
-	Name        string     `json:"name"`
-	StartPeriod *time.Time `json:"start_time"`
+	UserID      int        `json:"user_id" binding:"required,gt=0"`
+	Name        string     `json:"name" binding:"required,notblank,max=255"`
+	StartPeriod *time.Time `json:"start_time" binding:"omitempty,notfuture"`
 }
 
 type OutputTask struct {
-	Name     string `json:"name" `
-	Duration string `json:"duration"`
+	Id        int       `json:"id"`
+	Name      string    `json:"name" `
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  string    `json:"duration"`
+	Version   int       `json:"version"`
 }
 
+// TaskPage is one page of tasks, NextCursor is empty on the last page.
+type TaskPage struct {
+	Tasks      []OutputTask `json:"tasks"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      int          `json:"total"`
+}
+
+// InputTaskDelete deletes a task whose version is still Version and which
+// belongs to UserID, as InputTaskUpdate does.
 type InputTaskDelete struct {
-	UserID int `json:"user_id"`
-	TaskID int `json:"task_id"`
+	UserID  int `json:"user_id" binding:"required,gt=0"`
+	TaskID  int `json:"task_id" binding:"required,gt=0"`
+	Version int `json:"-"`
+}
+
+const (
+	TaskOpCreate = "create"
+	TaskOpStop   = "stop"
+	TaskOpDelete = "delete"
+)
+
+// TaskOperation is one operation of a batch: it creates a task, finishes it
+// as InputTaskUpdate does, or deletes it. Version stands in for the If-Match
+// header of stop and delete, zero matches any version.
+type TaskOperation struct {
+	Op        string     `json:"op" binding:"required,oneof=create stop delete"`
+	UserID    int        `json:"user_id" binding:"required,gt=0"`
+	TaskID    int        `json:"task_id" binding:"required_unless=Op create,omitempty,gt=0"`
+	Name      string     `json:"name" binding:"required_if=Op create,omitempty,notblank,max=255"`
+	StartTime *time.Time `json:"start_time" binding:"omitempty,notfuture"`
+	Version   int        `json:"version" binding:"omitempty,gt=0"`
+}
+
+// InputTaskBatch applies up to 100 operations in order. An atomic batch is
+// applied as a whole or not at all, otherwise every operation succeeds or
+// fails on its own.
+type InputTaskBatch struct {
+	Operations []TaskOperation `json:"operations" binding:"required,min=1,max=100"`
+	Atomic     bool            `json:"atomic"`
+}
+
+// TaskOperationResult is the outcome of a batch operation, Err is set when
+// it failed.
+type TaskOperationResult struct {
+	TaskID  int
+	Version int
+	Err     error
 }
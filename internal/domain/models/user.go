@@ -1,26 +1,69 @@
 package models
 
+import (
+	"regexp"
+	"time"
+)
+
+// PassportPattern is the expected passport format: a four digit series and
+// a six digit number separated by a space.
+var PassportPattern = regexp.MustCompile(`^\d{4} \d{6}$`)
+
 type User struct {
-	ID             int    `json:"id" db:"id"`
-	PassportNumber string `json:"passport_number" db:"passport_number"`
-	Name           string `json:"name" db:"name"`
-	Patronymic     string `json:"patronymic" db:"patronymic"`
-	Surname        string `json:"surname" db:"surname"`
-	Address        string `json:"addr" db:"addr"`
+	ID             int        `json:"id" db:"id"`
+	PassportNumber string     `json:"passport_number" db:"passport_number"`
+	Name           string     `json:"name" db:"name"`
+	Patronymic     string     `json:"patronymic" db:"patronymic"`
+	Surname        string     `json:"surname" db:"surname"`
+	Address        string     `json:"addr" db:"addr"`
+	Version        int        `json:"version" db:"version"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ErasedAt       *time.Time `json:"erased_at,omitempty" db:"erased_at"`
 }
 
+// UserExport is the personal data held about a user, including the tasks
+// that have been deleted but not purged yet.
+type UserExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	User       User      `json:"user"`
+	Tasks      []Task    `json:"tasks"`
+}
+
+// DefaultPageSize is the size of a page of a list when the client asks for
+// none, as the defaults of the limit query parameters of the lists set it.
+const DefaultPageSize = 50
+
 type QueryParams struct {
-	ID             string `form:"id"`
-	Name           string `form:"name"`
-	Surname        string `form:"surname"`
-	Patronymic     string `form:"patronymic"`
-	PassportNumber string `form:"passport_number"`
-	Address        string `form:"address"`
-	Limit          int    `form:"limit"`
-	Offset         int    `form:"offset"`
+	ID             string `form:"ID"`
+	Name           string `form:"Name"`
+	Surname        string `form:"Surname"`
+	Patronymic     string `form:"Patronymic"`
+	PassportNumber string `form:"PassportNumber"`
+	Address        string `form:"Address"`
+	Search         string `form:"Search"`
+	Limit          int    `form:"Limit,default=50" binding:"min=1,max=100"`
+	Cursor         string `form:"Cursor"`
+	Sort           string `form:"Sort" binding:"omitempty,sortby=id name surname"`
+}
+
+// UserPage is one page of users, NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+type InputUserCreate struct {
+	PassportNumber string `form:"PassportNumber" binding:"required,passport"`
 }
 
+// UpdateUserInput changes the given fields of a user whose version is still
+// Version, taken from the If-Match header. Zero matches any version.
 type UpdateUserInput struct {
-	PassportNumber *string `json:"passport_number"`
-	Address        *string `json:"addr"`
+	PassportNumber *string `json:"passport_number" binding:"omitempty,passport"`
+	Name           *string `json:"name" binding:"omitempty,notblank,max=255"`
+	Surname        *string `json:"surname" binding:"omitempty,notblank,max=255"`
+	Patronymic     *string `json:"patronymic" binding:"omitempty,notblank,max=255"`
+	Address        *string `json:"addr" binding:"omitempty,notblank,max=255"`
+	Version        int     `json:"-"`
 }
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook subscribes a URL to events of the given types. The deliveries
+// are signed with Secret, which is only returned when the webhook is created.
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type InputWebhookCreate struct {
+	URL        string   `json:"url" binding:"required,https_url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,unique,dive,oneof=task.started task.stopped task.updated task.deleted task.restored user.created user.updated user.deleted user.restored"`
+	// Secret is generated when left out.
+	Secret string `json:"secret" binding:"omitempty,min=16,max=255"`
+}
+
+// WebhookDelivery is an event queued for a webhook. A pending delivery is
+// sent at NextAttemptAt, it fails for good once it runs out of attempts.
+type WebhookDelivery struct {
+	ID            int64           `json:"id" db:"id"`
+	WebhookID     int             `json:"webhook_id" db:"webhook_id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	Payload       json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	Status        string          `json:"status" db:"status"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	// Log holds the attempts made so far, oldest first.
+	Log []WebhookAttempt `json:"log" db:"-"`
+}
+
+// WebhookAttempt records a try to send a delivery, StatusCode is missing
+// when no response was received.
+type WebhookAttempt struct {
+	DeliveryID  int64     `json:"-" db:"delivery_id"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+	DurationMS  int       `json:"duration_ms" db:"duration_ms"`
+	StatusCode  *int      `json:"status_code,omitempty" db:"status_code"`
+	Response    *string   `json:"response,omitempty" db:"response_body"`
+	Error       *string   `json:"error,omitempty" db:"error"`
+}
+
+// OutgoingWebhook is a delivery claimed for sending, along with the target
+// of its webhook.
+type OutgoingWebhook struct {
+	WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// WebhookDeliveryFilter selects the deliveries of a webhook, newest first.
+type WebhookDeliveryFilter struct {
+	WebhookID int    `json:"-" form:"-"`
+	Status    string `json:"status" form:"status" binding:"omitempty,oneof=pending delivered failed"`
+	Limit     int    `json:"limit" form:"limit,default=50" binding:"min=1,max=100"`
+	Cursor    string `json:"cursor" form:"cursor"`
+}
+
+// WebhookDeliveryPage is one page of deliveries, NextCursor is empty on the
+// last page.
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
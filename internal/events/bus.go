@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Bus delivers the events published by the services to the subscribers of
+// this instance. The latest events are kept, so that a subscriber that lost
+// its connection can resume where it stopped.
+type Bus struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []models.Event
+	subs    map[*subscriber]struct{}
+	closed  bool
+
+	cfg config.EventsConfig
+	log *slog.Logger
+}
+
+type subscriber struct {
+	ch     chan models.Event
+	filter models.EventFilter
+}
+
+func NewBus(cfg config.EventsConfig, log *slog.Logger) *Bus {
+	return &Bus{
+		// IDs continue from the start time, so that they keep growing
+		// across restarts and a stale Last-Event-ID replays the history
+		lastID: uint64(time.Now().UnixMicro()),
+		subs:   make(map[*subscriber]struct{}),
+		cfg:    cfg,
+		log:    log,
+	}
+}
+
+// Publish assigns the event its ID and hands it to the matching
+// subscribers without blocking. A subscriber whose buffer is full is
+// disconnected instead.
+func (b *Bus) Publish(event models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	if b.cfg.History > 0 {
+		if len(b.history) == b.cfg.History {
+			b.history = b.history[1:]
+		}
+		b.history = append(b.history, event)
+	}
+
+	for s := range b.subs {
+		if !s.filter.Match(event) {
+			continue
+		}
+		select {
+		case s.ch <- event:
+		default:
+			b.log.Warn("disconnecting slow event subscriber", slog.Uint64("event_id", event.ID))
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe returns the events matching the filter, starting with the
+// kept ones published after filter.LastEventID. The channel is closed when
+// ctx is done, the subscriber falls behind or the bus is closed.
+func (b *Bus) Subscribe(ctx context.Context, filter models.EventFilter) <-chan models.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []models.Event
+	if filter.LastEventID != 0 {
+		for _, e := range b.history {
+			if e.ID > filter.LastEventID && filter.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	s := &subscriber{ch: make(chan models.Event, b.cfg.Buffer+len(replay)), filter: filter}
+	for _, e := range replay {
+		s.ch <- e
+	}
+
+	if b.closed {
+		close(s.ch)
+		return s.ch
+	}
+
+	b.subs[s] = struct{}{}
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(s)
+	})
+
+	return s.ch
+}
+
+// Close disconnects every subscriber, the events published afterwards are
+// dropped.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// remove must be called with b.mu held.
+func (b *Bus) remove(s *subscriber) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+}
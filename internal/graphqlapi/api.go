@@ -0,0 +1,64 @@
+// Package graphqlapi serves users, tasks and the time spent on them as a
+// GraphQL schema for the dashboards. Nested fields are loaded in batches
+// per request, so a page of users with their tasks takes a fixed number
+// of queries.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/graph-gophers/graphql-go"
+	"log/slog"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// The limits bound the work a single query can ask for, the loads being
+// batched per field and not per query: every root field, aliases included,
+// and every period of the tasks of users runs queries of its own. They are
+// documented in schema.graphql.
+const (
+	maxDepth       = 8
+	maxQueryLength = 8 << 10
+	maxRootFields  = 10
+	maxPeriods     = 10
+	// maxParallelism lets the nested fields of a whole page of users be
+	// resolved at once, fewer would split their loads into several batches.
+	maxParallelism = 500
+)
+
+// Request is a GraphQL query with its variables.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type API struct {
+	schema  *graphql.Schema
+	service *service.Service
+	log     *slog.Logger
+}
+
+func New(services *service.Service, log *slog.Logger) *API {
+	api := &API{
+		service: services,
+		log:     log,
+	}
+	api.schema = graphql.MustParseSchema(schema, &queryResolver{api: api},
+		graphql.MaxDepth(maxDepth),
+		graphql.MaxQueryLength(maxQueryLength),
+		graphql.MaxParallelism(maxParallelism),
+	)
+
+	return api
+}
+
+// Exec runs the request with loaders of its own, the loaded records are
+// never shared between requests.
+func (a *API) Exec(ctx context.Context, req Request) *graphql.Response {
+	ctx = withLoaders(ctx, newLoaders(a.service))
+	return a.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
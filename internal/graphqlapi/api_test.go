@@ -0,0 +1,134 @@
+package graphqlapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/graph-gophers/graphql-go"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type fakeUsers struct {
+	service.UserProvider
+	params *models.QueryParams
+}
+
+func (f *fakeUsers) Users(_ context.Context, params models.QueryParams) (models.UserPage, error) {
+	f.params = &params
+	return models.UserPage{}, nil
+}
+
+func (*fakeUsers) UsersByIDs(_ context.Context, ids []int) ([]models.User, error) {
+	users := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		users = append(users, models.User{ID: id, Name: "Ivan"})
+	}
+	return users, nil
+}
+
+type fakeTasks struct {
+	service.TaskProvider
+	loads atomic.Int32
+}
+
+func (f *fakeTasks) TaskById(_ context.Context, id int) (models.Task, error) {
+	return models.Task{Id: id, Name: "Code review"}, nil
+}
+
+func (f *fakeTasks) TasksByUsers(context.Context, []int, models.TaskPeriod) ([]models.Task, error) {
+	f.loads.Add(1)
+	return nil, nil
+}
+
+func exec(t *testing.T, query string) (*graphql.Response, *fakeTasks) {
+	t.Helper()
+
+	resp, _, tasks := execWith(query)
+	return resp, tasks
+}
+
+func execWith(query string) (*graphql.Response, *fakeUsers, *fakeTasks) {
+	users, tasks := &fakeUsers{}, &fakeTasks{}
+	api := New(&service.Service{UserProvider: users, TaskProvider: tasks}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return api.Exec(context.Background(), Request{Query: query}), users, tasks
+}
+
+func TestRootFieldLimit(t *testing.T) {
+	var fields []string
+	for i := 0; i < maxRootFields; i++ {
+		fields = append(fields, fmt.Sprintf("u%d: user(id: %d) { name }", i, i+1))
+	}
+
+	if resp, _ := exec(t, "{ "+strings.Join(fields, " ")+" }"); len(resp.Errors) != 0 {
+		t.Fatalf("errors within the limit: %v", resp.Errors)
+	}
+
+	fields = append(fields, "over: task(id: 1) { name }")
+	resp, _ := exec(t, "{ "+strings.Join(fields, " ")+" }")
+	if len(resp.Errors) != 1 {
+		t.Fatalf("errors = %v, want one for a field over the limit", resp.Errors)
+	}
+	if code := resp.Errors[0].Extensions["code"]; code != codeBadUserInput {
+		t.Fatalf("code = %v, want %s", code, codeBadUserInput)
+	}
+}
+
+func TestPeriodLimit(t *testing.T) {
+	var fields []string
+	for i := 0; i <= maxPeriods; i++ {
+		fields = append(fields, fmt.Sprintf(`p%d: totals(from: "2024-07-%02dT00:00:00Z") { tasks }`, i, i+1))
+	}
+
+	resp, tasks := exec(t, "{ user(id: 1) { "+strings.Join(fields, " ")+" } }")
+	if len(resp.Errors) == 0 {
+		t.Fatal("no error past the limit of periods")
+	}
+	if n := tasks.loads.Load(); n != maxPeriods {
+		t.Fatalf("tasks loaded %d times, want %d", n, maxPeriods)
+	}
+
+	// the same period under several aliases is loaded once
+	resp, tasks = exec(t, `{ user(id: 1) { a: tasks { name } b: tasks { name } c: totals { tasks } } }`)
+	if len(resp.Errors) != 0 || tasks.loads.Load() != 1 {
+		t.Fatalf("errors %v, %d loads, want a single load", resp.Errors, tasks.loads.Load())
+	}
+}
+
+func TestQueryLengthLimit(t *testing.T) {
+	query := "{ user(id: 1) { name } }" + strings.Repeat(" ", maxQueryLength)
+
+	resp, _ := exec(t, query)
+	if len(resp.Errors) == 0 || resp.Data != nil {
+		t.Fatalf("response %s, %v, want the query rejected", resp.Data, resp.Errors)
+	}
+}
+
+func TestUsersPageSize(t *testing.T) {
+	tests := []struct {
+		query string
+		limit int
+	}{
+		{query: `{ users { total } }`, limit: models.DefaultPageSize},
+		{query: `{ users(first: null) { total } }`, limit: models.DefaultPageSize},
+		{query: `{ users(first: 20) { total } }`, limit: 20},
+	}
+
+	for _, tt := range tests {
+		resp, users, _ := execWith(tt.query)
+		if len(resp.Errors) != 0 {
+			t.Fatalf("%s: %v", tt.query, resp.Errors)
+		}
+		if users.params == nil || users.params.Limit != tt.limit {
+			t.Errorf("%s: service got %+v, want limit %d", tt.query, users.params, tt.limit)
+		}
+	}
+
+	if resp, users, _ := execWith(`{ users(first: 0) { total } }`); len(resp.Errors) == 0 || users.params != nil {
+		t.Error("users(first: 0) reached the service")
+	}
+}
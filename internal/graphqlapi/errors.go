@@ -0,0 +1,96 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/3XBAT/time-tracker/internal/validation"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+)
+
+const (
+	codeBadUserInput = "BAD_USER_INPUT"
+	codeNotFound     = "NOT_FOUND"
+	codeInternal     = "INTERNAL"
+)
+
+// errorCodes maps domain errors to the code in the extensions of a GraphQL
+// error. The message of these errors is safe to send.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{storage.ErrUserNotFound, codeNotFound},
+	{storage.ErrTaskNotFound, codeNotFound},
+	{storage.ErrInvalidSort, codeBadUserInput},
+	{storage.ErrInvalidFilter, codeBadUserInput},
+	{storage.ErrInvalidCursor, codeBadUserInput},
+	{storage.ErrBadRequest, codeBadUserInput},
+}
+
+// queryError is an error safe to show to clients, graphql-go puts its
+// extensions in the response.
+type queryError struct {
+	message string
+	code    string
+	// fields maps the invalid arguments to what is wrong with them.
+	fields map[string]string
+}
+
+func (e *queryError) Error() string {
+	return e.message
+}
+
+func (e *queryError) Extensions() map[string]any {
+	ext := map[string]any{"code": e.code}
+	if len(e.fields) > 0 {
+		ext["fields"] = e.fields
+	}
+	return ext
+}
+
+func invalidArgument(format string, args ...any) error {
+	return &queryError{message: fmt.Sprintf(format, args...), code: codeBadUserInput}
+}
+
+// validate runs the validation rules of input, names maps its fields to the
+// arguments they were taken from.
+func validate(input any, names map[string]string) error {
+	var verrs validator.ValidationErrors
+	if err := validation.Struct(input); errors.As(err, &verrs) {
+		fields := make(map[string]string, len(verrs))
+		for _, fe := range verrs {
+			name, ok := names[fe.Field()]
+			if !ok {
+				name = fe.Field()
+			}
+			fields[name] = validation.Message(fe)
+		}
+		return &queryError{message: "one or more arguments are invalid", code: codeBadUserInput, fields: fields}
+	} else if err != nil {
+		return invalidArgument("%s", err.Error())
+	}
+
+	return nil
+}
+
+// fail converts err into an error safe to send, internal details are
+// logged instead.
+func (a *API) fail(ctx context.Context, err error) error {
+	var qerr *queryError
+	if errors.As(err, &qerr) {
+		return qerr
+	}
+
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return &queryError{message: e.err.Error(), code: e.code}
+		}
+	}
+
+	logger.FromContext(ctx, a.log).Error("graphql resolver failed", slog.String("error", err.Error()))
+	return &queryError{message: "internal error", code: codeInternal}
+}
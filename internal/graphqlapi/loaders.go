@@ -0,0 +1,159 @@
+package graphqlapi
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/graph-gophers/dataloader/v7"
+	"sync"
+	"time"
+)
+
+const (
+	// loadWait is how long a loader collects keys before running its batch.
+	loadWait = 5 * time.Millisecond
+	// maxBatch is the largest page of users.
+	maxBatch = 100
+)
+
+type loadersKey struct{}
+
+// periodKey identifies a period, the bounds that are not set are empty.
+type periodKey struct {
+	from, to string
+}
+
+// loaders batch the loads of a single request: every user asked for by the
+// fields resolved together is read with one query, and so are the tasks of
+// every user for the same period.
+type loaders struct {
+	service *service.Service
+	users   *dataloader.Loader[int, *models.User]
+
+	mu         sync.Mutex
+	tasks      map[periodKey]*dataloader.Loader[int, []models.Task]
+	rootFields int
+}
+
+func newLoaders(services *service.Service) *loaders {
+	l := &loaders{
+		service: services,
+		tasks:   make(map[periodKey]*dataloader.Loader[int, []models.Task]),
+	}
+	l.users = dataloader.NewBatchedLoader(l.loadUsers,
+		dataloader.WithWait[int, *models.User](loadWait),
+		dataloader.WithBatchCapacity[int, *models.User](maxBatch),
+	)
+
+	return l
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// user returns nil when the user does not exist.
+func (l *loaders) user(ctx context.Context, id int) (*models.User, error) {
+	return l.users.Load(ctx, id)()
+}
+
+// primeUser keeps a user that has already been read for the later loads.
+func (l *loaders) primeUser(ctx context.Context, user models.User) {
+	l.users.Prime(ctx, user.ID, &user)
+}
+
+// rootField counts a root field of the request, it fails past maxRootFields.
+func (l *loaders) rootField() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rootFields++
+	if l.rootFields > maxRootFields {
+		return invalidArgument("query has more than %d root fields", maxRootFields)
+	}
+	return nil
+}
+
+func (l *loaders) tasksOf(ctx context.Context, userID int, period models.TaskPeriod) ([]models.Task, error) {
+	loader, err := l.tasksLoader(period)
+	if err != nil {
+		return nil, err
+	}
+	return loader.Load(ctx, userID)()
+}
+
+// tasksLoader returns the loader of the period, a request asks for the
+// tasks of at most maxPeriods periods.
+func (l *loaders) tasksLoader(period models.TaskPeriod) (*dataloader.Loader[int, []models.Task], error) {
+	var key periodKey
+	if period.From != nil {
+		key.from = period.From.Format(time.RFC3339Nano)
+	}
+	if period.To != nil {
+		key.to = period.To.Format(time.RFC3339Nano)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	loader, ok := l.tasks[key]
+	if !ok {
+		if len(l.tasks) == maxPeriods {
+			return nil, invalidArgument("query asks for the tasks of more than %d periods", maxPeriods)
+		}
+		loader = dataloader.NewBatchedLoader(func(ctx context.Context, userIDs []int) []*dataloader.Result[[]models.Task] {
+			return l.loadTasks(ctx, userIDs, period)
+		},
+			dataloader.WithWait[int, []models.Task](loadWait),
+			dataloader.WithBatchCapacity[int, []models.Task](maxBatch),
+		)
+		l.tasks[key] = loader
+	}
+	return loader, nil
+}
+
+func (l *loaders) loadUsers(ctx context.Context, ids []int) []*dataloader.Result[*models.User] {
+	results := make([]*dataloader.Result[*models.User], len(ids))
+
+	users, err := l.service.UserProvider.UsersByIDs(ctx, ids)
+	if err != nil {
+		for i := range results {
+			results[i] = &dataloader.Result[*models.User]{Error: err}
+		}
+		return results
+	}
+
+	byID := make(map[int]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	for i, id := range ids {
+		results[i] = &dataloader.Result[*models.User]{Data: byID[id]}
+	}
+	return results
+}
+
+func (l *loaders) loadTasks(ctx context.Context, userIDs []int, period models.TaskPeriod) []*dataloader.Result[[]models.Task] {
+	results := make([]*dataloader.Result[[]models.Task], len(userIDs))
+
+	tasks, err := l.service.TaskProvider.TasksByUsers(ctx, userIDs, period)
+	if err != nil {
+		for i := range results {
+			results[i] = &dataloader.Result[[]models.Task]{Error: err}
+		}
+		return results
+	}
+
+	byUser := make(map[int][]models.Task, len(userIDs))
+	for _, task := range tasks {
+		byUser[task.UserID] = append(byUser[task.UserID], task)
+	}
+	for i, id := range userIDs {
+		results[i] = &dataloader.Result[[]models.Task]{Data: byUser[id]}
+	}
+	return results
+}
@@ -0,0 +1,309 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/graph-gophers/graphql-go"
+	"strconv"
+)
+
+type queryResolver struct {
+	api *API
+}
+
+type userFilter struct {
+	ID             *string
+	Name           *string
+	Surname        *string
+	Patronymic     *string
+	PassportNumber *string
+	Address        *string
+	Search         *string
+}
+
+type usersArgs struct {
+	Filter *userFilter
+	First  *int32
+	After  *string
+	Sort   *string
+}
+
+func (r *queryResolver) Users(ctx context.Context, args usersArgs) (*userConnectionResolver, error) {
+	if err := loadersFrom(ctx).rootField(); err != nil {
+		return nil, err
+	}
+
+	params := models.QueryParams{
+		Limit:  models.DefaultPageSize,
+		Cursor: value(args.After),
+		Sort:   value(args.Sort),
+	}
+	if args.First != nil {
+		params.Limit = int(*args.First)
+	}
+	if f := args.Filter; f != nil {
+		params.ID = value(f.ID)
+		params.Name = value(f.Name)
+		params.Surname = value(f.Surname)
+		params.Patronymic = value(f.Patronymic)
+		params.PassportNumber = value(f.PassportNumber)
+		params.Address = value(f.Address)
+		params.Search = value(f.Search)
+	}
+	if err := validate(params, map[string]string{"Limit": "first", "Sort": "sort"}); err != nil {
+		return nil, err
+	}
+
+	page, err := r.api.service.UserProvider.Users(ctx, params)
+	if err != nil {
+		return nil, r.api.fail(ctx, err)
+	}
+
+	loaders := loadersFrom(ctx)
+	conn := &userConnectionResolver{page: page}
+	for _, user := range page.Users {
+		loaders.primeUser(ctx, user)
+		conn.nodes = append(conn.nodes, &userResolver{api: r.api, user: user})
+	}
+	return conn, nil
+}
+
+func (r *queryResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	if err := loadersFrom(ctx).rootField(); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := loadersFrom(ctx).user(ctx, id)
+	if err != nil {
+		return nil, r.api.fail(ctx, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &userResolver{api: r.api, user: *user}, nil
+}
+
+func (r *queryResolver) Task(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	if err := loadersFrom(ctx).rootField(); err != nil {
+		return nil, err
+	}
+
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := r.api.service.TaskProvider.TaskById(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrTaskNotFound) {
+			return nil, nil
+		}
+		return nil, r.api.fail(ctx, err)
+	}
+	return &taskResolver{api: r.api, task: task}, nil
+}
+
+type userConnectionResolver struct {
+	page  models.UserPage
+	nodes []*userResolver
+}
+
+func (r *userConnectionResolver) Nodes() []*userResolver {
+	return r.nodes
+}
+
+func (r *userConnectionResolver) NextCursor() *string {
+	if r.page.NextCursor == "" {
+		return nil
+	}
+	return &r.page.NextCursor
+}
+
+func (r *userConnectionResolver) Total() int32 {
+	return int32(r.page.Total)
+}
+
+type userResolver struct {
+	api  *API
+	user models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.user.ID))
+}
+
+func (r *userResolver) PassportNumber() string {
+	return r.user.PassportNumber
+}
+
+func (r *userResolver) Name() string {
+	return r.user.Name
+}
+
+func (r *userResolver) Surname() string {
+	return r.user.Surname
+}
+
+func (r *userResolver) Patronymic() string {
+	return r.user.Patronymic
+}
+
+func (r *userResolver) Address() string {
+	return r.user.Address
+}
+
+func (r *userResolver) Version() int32 {
+	return int32(r.user.Version)
+}
+
+type periodArgs struct {
+	From *graphql.Time
+	To   *graphql.Time
+}
+
+func (a periodArgs) period() (models.TaskPeriod, error) {
+	var period models.TaskPeriod
+	if a.From != nil {
+		period.From = &a.From.Time
+	}
+	if a.To != nil {
+		period.To = &a.To.Time
+	}
+	if period.From != nil && period.To != nil && period.To.Before(*period.From) {
+		return period, invalidArgument("to must not be before from")
+	}
+	return period, nil
+}
+
+func (r *userResolver) Tasks(ctx context.Context, args periodArgs) ([]*taskResolver, error) {
+	tasks, err := r.tasks(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*taskResolver, 0, len(tasks))
+	for _, task := range tasks {
+		resolvers = append(resolvers, &taskResolver{api: r.api, task: task})
+	}
+	return resolvers, nil
+}
+
+func (r *userResolver) Totals(ctx context.Context, args periodArgs) (*totalsResolver, error) {
+	tasks, err := r.tasks(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := &totalsResolver{tasks: int32(len(tasks))}
+	for _, task := range tasks {
+		if task.EndTime == nil {
+			totals.running++
+			continue
+		}
+		totals.finished++
+		totals.duration += int32(task.EndTime.Sub(task.StartTime).Seconds())
+	}
+	return totals, nil
+}
+
+func (r *userResolver) tasks(ctx context.Context, args periodArgs) ([]models.Task, error) {
+	period, err := args.period()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := loadersFrom(ctx).tasksOf(ctx, r.user.ID, period)
+	if err != nil {
+		return nil, r.api.fail(ctx, err)
+	}
+	return tasks, nil
+}
+
+type taskResolver struct {
+	api  *API
+	task models.Task
+}
+
+func (r *taskResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.task.Id))
+}
+
+func (r *taskResolver) Name() string {
+	return r.task.Name
+}
+
+func (r *taskResolver) StartTime() graphql.Time {
+	return graphql.Time{Time: r.task.StartTime}
+}
+
+func (r *taskResolver) EndTime() *graphql.Time {
+	if r.task.EndTime == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.task.EndTime}
+}
+
+func (r *taskResolver) Duration() *int32 {
+	if r.task.EndTime == nil {
+		return nil
+	}
+	d := int32(r.task.EndTime.Sub(r.task.StartTime).Seconds())
+	return &d
+}
+
+func (r *taskResolver) Version() int32 {
+	return int32(r.task.Version)
+}
+
+func (r *taskResolver) User(ctx context.Context) (*userResolver, error) {
+	user, err := loadersFrom(ctx).user(ctx, r.task.UserID)
+	if err != nil {
+		return nil, r.api.fail(ctx, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &userResolver{api: r.api, user: *user}, nil
+}
+
+type totalsResolver struct {
+	tasks, finished, running, duration int32
+}
+
+func (r *totalsResolver) Tasks() int32 {
+	return r.tasks
+}
+
+func (r *totalsResolver) Finished() int32 {
+	return r.finished
+}
+
+func (r *totalsResolver) Running() int32 {
+	return r.running
+}
+
+func (r *totalsResolver) Duration() int32 {
+	return r.duration
+}
+
+func parseID(id graphql.ID) (int, error) {
+	n, err := strconv.Atoi(string(id))
+	if err != nil || n <= 0 {
+		return 0, invalidArgument("invalid id: %s", id)
+	}
+	return n, nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,131 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/api"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/3XBAT/time-tracker/internal/validation"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// errorCodes maps domain errors to status codes, the counterpart of the
+// errorKinds of the REST API. The message of these errors is safe to send.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{storage.ErrUserNotFound, codes.NotFound},
+	{storage.ErrTaskNotFound, codes.NotFound},
+	{storage.ErrWebhookNotFound, codes.NotFound},
+	{storage.ErrDeliveryNotFound, codes.NotFound},
+	{storage.ErrTaskEnded, codes.FailedPrecondition},
+	{storage.ErrTaskRunning, codes.FailedPrecondition},
+	{storage.ErrInvalidPeriod, codes.InvalidArgument},
+	{storage.ErrUserExists, codes.AlreadyExists},
+	{storage.ErrInvalidSort, codes.InvalidArgument},
+	{storage.ErrInvalidFilter, codes.InvalidArgument},
+	{storage.ErrInvalidCursor, codes.InvalidArgument},
+	{storage.ErrVersionMismatch, codes.Aborted},
+	{storage.ErrNothingToUpdate, codes.InvalidArgument},
+	{storage.ErrBadRequest, codes.InvalidArgument},
+	{service.ErrInvalidPassport, codes.InvalidArgument},
+	{api.ErrPersonNotFound, codes.NotFound},
+	{api.ErrInvalidPassport, codes.InvalidArgument},
+	{api.ErrUnavailable, codes.Unavailable},
+}
+
+// fieldViolation is a request field that failed validation.
+type fieldViolation struct {
+	field, description string
+}
+
+// validationError carries every failed field of a request.
+type validationError struct {
+	fields []fieldViolation
+}
+
+func (e *validationError) Error() string {
+	return "validation failed"
+}
+
+// validate runs the validation rules of input, the extra violations are
+// reported along with the failed rules.
+func validate(input any, extra ...fieldViolation) error {
+	fields := extra
+
+	var verrs validator.ValidationErrors
+	if err := validation.Struct(input); errors.As(err, &verrs) {
+		for _, fe := range verrs {
+			fields = append(fields, fieldViolation{field: fieldName(fe.Field()), description: validation.Message(fe)})
+		}
+	} else if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &validationError{fields: fields}
+}
+
+// fieldName turns the name of a model field, as the REST API reports it,
+// into the name of the request field.
+func fieldName(name string) string {
+	if name == "addr" {
+		return "address"
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// toStatus converts err into a status. Internal details are logged and
+// never sent to clients.
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var valErr *validationError
+	if errors.As(err, &valErr) {
+		br := &errdetails.BadRequest{}
+		for _, f := range valErr.fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.field,
+				Description: f.description,
+			})
+		}
+		st, detailErr := status.New(codes.InvalidArgument, "one or more fields are invalid").WithDetails(br)
+		if detailErr != nil {
+			return status.Error(codes.InvalidArgument, "one or more fields are invalid")
+		}
+		return st.Err()
+	}
+
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, e.err.Error())
+		}
+	}
+
+	logger.FromContext(ctx, s.log).Error("call failed", slog.String("error", err.Error()))
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,50 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/api"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{storage.ErrUserNotFound, codes.NotFound},
+		{storage.ErrTaskNotFound, codes.NotFound},
+		{storage.ErrWebhookNotFound, codes.NotFound},
+		{storage.ErrDeliveryNotFound, codes.NotFound},
+		{storage.ErrTaskEnded, codes.FailedPrecondition},
+		{storage.ErrTaskRunning, codes.FailedPrecondition},
+		{storage.ErrInvalidPeriod, codes.InvalidArgument},
+		{storage.ErrUserExists, codes.AlreadyExists},
+		{storage.ErrInvalidSort, codes.InvalidArgument},
+		{storage.ErrInvalidFilter, codes.InvalidArgument},
+		{storage.ErrInvalidCursor, codes.InvalidArgument},
+		{storage.ErrVersionMismatch, codes.Aborted},
+		{storage.ErrNothingToUpdate, codes.InvalidArgument},
+		{storage.ErrBadRequest, codes.InvalidArgument},
+		{service.ErrInvalidPassport, codes.InvalidArgument},
+		{api.ErrPersonNotFound, codes.NotFound},
+		{api.ErrInvalidPassport, codes.InvalidArgument},
+		{api.ErrUnavailable, codes.Unavailable},
+		{errors.New("connection refused"), codes.Internal},
+	}
+
+	s := &Server{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	for _, tt := range tests {
+		err := s.toStatus(context.Background(), fmt.Errorf("storage.op: %w", tt.err))
+		if got := status.Code(err); got != tt.code {
+			t.Errorf("%v: code = %s, want %s", tt.err, got, tt.code)
+		}
+	}
+}
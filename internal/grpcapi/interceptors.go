@@ -0,0 +1,113 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/audit"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	requestIDKey = "x-request-id"
+	actorKey     = "x-actor"
+)
+
+// assignRequestID does for gRPC calls what the middleware of the same name
+// does for HTTP requests: the request id is taken from the x-request-id
+// metadata or generated, sent back in the header and, along with the
+// x-actor metadata, attributes the changes made by the call in the audit log.
+func (s *Server) assignRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := metadataValue(md, requestIDKey)
+	if id == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+	actor := metadataValue(md, actorKey)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	log := s.log.With(slog.String("request_id", id))
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		log = log.With(slog.String("trace_id", sc.TraceID().String()))
+	}
+
+	ctx = logger.WithContext(ctx, log)
+	ctx = audit.WithOrigin(ctx, audit.Origin{Actor: actor, RequestID: id})
+
+	return next(ctx, req)
+}
+
+// logCalls writes one access log line per call.
+func (s *Server) logCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	resp, err := next(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	logger.FromContext(ctx, s.log).LogAttrs(ctx, level, "call completed",
+		slog.String("method", info.FullMethod),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	)
+
+	return resp, err
+}
+
+// convertErrors turns the errors returned by the services into statuses,
+// as handleErrors does for HTTP.
+func (s *Server) convertErrors(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	resp, err := next(ctx, req)
+	if err != nil {
+		return resp, s.toStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// recoverPanics turns a panic in a call into an internal error.
+func (s *Server) recoverPanics(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(ctx, s.log).Error("recovered from panic",
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())),
+			)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return next(ctx, req)
+}
+
+// metadataValue returns the first value of key if it is safe to log and echo.
+func metadataValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 || !logger.ValidRequestID(values[0]) {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,60 @@
+package grpcapi
+
+import (
+	"context"
+	timetrackerv1 "github.com/3XBAT/time-tracker/api/timetracker/v1"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type reportServer struct {
+	timetrackerv1.UnimplementedReportServiceServer
+	service *service.Service
+}
+
+func (s *reportServer) GetWorkload(ctx context.Context, req *timetrackerv1.GetWorkloadRequest) (*timetrackerv1.GetWorkloadResponse, error) {
+	input := models.InputTask{
+		UserID: int(req.GetUserId()),
+		Limit:  int(req.GetLimit()),
+		Cursor: req.GetCursor(),
+		Sort:   req.GetSort(),
+	}
+	if input.Limit == 0 {
+		input.Limit = models.DefaultPageSize
+	}
+	if req.StartTime != nil {
+		start := req.GetStartTime().AsTime()
+		input.StartPeriod = &start
+	}
+	if req.EndTime != nil {
+		end := req.GetEndTime().AsTime()
+		input.EndPeriod = &end
+	}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.TaskProvider.Tasks(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &timetrackerv1.GetWorkloadResponse{
+		Tasks:      make([]*timetrackerv1.TaskTime, 0, len(page.Tasks)),
+		NextCursor: page.NextCursor,
+		Total:      int32(page.Total),
+	}
+	for _, t := range page.Tasks {
+		resp.Tasks = append(resp.Tasks, &timetrackerv1.TaskTime{
+			Id:        int64(t.Id),
+			Name:      t.Name,
+			StartTime: timestamppb.New(t.StartTime),
+			EndTime:   timestamppb.New(t.EndTime),
+			Duration:  durationpb.New(t.EndTime.Sub(t.StartTime)),
+			Version:   int32(t.Version),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,85 @@
+// Package grpcapi serves the users, tasks and reports over gRPC, next to
+// the REST API and on top of the same services.
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	timetrackerv1 "github.com/3XBAT/time-tracker/api/timetracker/v1"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"log/slog"
+	"net"
+)
+
+type Server struct {
+	grpcServer *grpc.Server
+	health     *health.Server
+	log        *slog.Logger
+}
+
+// NewServer registers the API services along with the standard health
+// checking and reflection services.
+func NewServer(log *slog.Logger, services *service.Service) *Server {
+	s := &Server{
+		health: health.NewServer(),
+		log:    log,
+	}
+
+	s.grpcServer = grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			s.assignRequestID,
+			s.logCalls,
+			s.convertErrors,
+			s.recoverPanics,
+		),
+	)
+
+	timetrackerv1.RegisterUserServiceServer(s.grpcServer, &userServer{service: services})
+	timetrackerv1.RegisterTaskServiceServer(s.grpcServer, &taskServer{service: services})
+	timetrackerv1.RegisterReportServiceServer(s.grpcServer, &reportServer{service: services})
+
+	healthpb.RegisterHealthServer(s.grpcServer, s.health)
+	for name := range s.grpcServer.GetServiceInfo() {
+		s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	reflection.Register(s.grpcServer)
+
+	return s
+}
+
+func (s *Server) Run(port string) error {
+	const op = "grpcapi.Server.Run"
+
+	lis, err := net.Listen("tcp", port)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.grpcServer.Serve(lis)
+}
+
+// ShutDown reports the services as not serving and waits for the running
+// calls to finish, the calls still running when ctx is done are cancelled.
+func (s *Server) ShutDown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,121 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	timetrackerv1 "github.com/3XBAT/time-tracker/api/timetracker/v1"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+type taskServer struct {
+	timetrackerv1.UnimplementedTaskServiceServer
+	service *service.Service
+}
+
+func (s *taskServer) CreateTask(ctx context.Context, req *timetrackerv1.CreateTaskRequest) (*timetrackerv1.CreateTaskResponse, error) {
+	input := models.InputTaskCreate{
+		UserID: int(req.GetUserId()),
+		Name:   req.GetName(),
+	}
+	if req.StartTime != nil {
+		start := req.GetStartTime().AsTime()
+		input.StartPeriod = &start
+	}
+
+	var extra []fieldViolation
+	if input.UserID > 0 {
+		violation, err := userExists(ctx, s.service, input.UserID)
+		if err != nil {
+			return nil, err
+		}
+		extra = violation
+	}
+	if err := validate(input, extra...); err != nil {
+		return nil, err
+	}
+
+	id, err := s.service.TaskProvider.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.CreateTaskResponse{Id: int64(id)}, nil
+}
+
+func (s *taskServer) GetTask(ctx context.Context, req *timetrackerv1.GetTaskRequest) (*timetrackerv1.GetTaskResponse, error) {
+	task, err := s.service.TaskProvider.TaskById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.GetTaskResponse{Task: toTask(task)}, nil
+}
+
+func (s *taskServer) StopTask(ctx context.Context, req *timetrackerv1.StopTaskRequest) (*timetrackerv1.StopTaskResponse, error) {
+	updated, err := s.service.TaskProvider.Update(ctx, models.InputTaskUpdate{
+		Id:      int(req.GetId()),
+		UserID:  int(req.GetUserId()),
+		Version: int(req.GetVersion()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.StopTaskResponse{Task: toTask(updated)}, nil
+}
+
+func (s *taskServer) DeleteTask(ctx context.Context, req *timetrackerv1.DeleteTaskRequest) (*timetrackerv1.DeleteTaskResponse, error) {
+	err := s.service.TaskProvider.Delete(ctx, models.InputTaskDelete{
+		TaskID:  int(req.GetId()),
+		UserID:  int(req.GetUserId()),
+		Version: int(req.GetVersion()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.DeleteTaskResponse{}, nil
+}
+
+func (s *taskServer) RestoreTask(ctx context.Context, req *timetrackerv1.RestoreTaskRequest) (*timetrackerv1.RestoreTaskResponse, error) {
+	if err := s.service.TaskProvider.Restore(ctx, int(req.GetId())); err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.RestoreTaskResponse{}, nil
+}
+
+// userExists reports a user_id that does not reference an existing user as
+// a field violation, as checkUserExists does for the REST API.
+func userExists(ctx context.Context, services *service.Service, userID int) ([]fieldViolation, error) {
+	if _, err := services.UserProvider.UserById(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return []fieldViolation{{field: "user_id", description: "does not reference an existing user"}}, nil
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func toTask(t models.Task) *timetrackerv1.Task {
+	return &timetrackerv1.Task{
+		Id:        int64(t.Id),
+		UserId:    int64(t.UserID),
+		Name:      t.Name,
+		StartTime: timestamppb.New(t.StartTime),
+		EndTime:   optionalTimestamp(t.EndTime),
+		Version:   int32(t.Version),
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
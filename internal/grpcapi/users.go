@@ -0,0 +1,121 @@
+package grpcapi
+
+import (
+	"context"
+	timetrackerv1 "github.com/3XBAT/time-tracker/api/timetracker/v1"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+)
+
+type userServer struct {
+	timetrackerv1.UnimplementedUserServiceServer
+	service *service.Service
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *timetrackerv1.ListUsersRequest) (*timetrackerv1.ListUsersResponse, error) {
+	params := models.QueryParams{
+		ID:             req.GetId(),
+		Name:           req.GetName(),
+		Surname:        req.GetSurname(),
+		Patronymic:     req.GetPatronymic(),
+		PassportNumber: req.GetPassportNumber(),
+		Address:        req.GetAddress(),
+		Search:         req.GetSearch(),
+		Limit:          int(req.GetLimit()),
+		Cursor:         req.GetCursor(),
+		Sort:           req.GetSort(),
+	}
+	if params.Limit == 0 {
+		params.Limit = models.DefaultPageSize
+	}
+	if err := validate(params); err != nil {
+		return nil, err
+	}
+
+	page, err := s.service.UserProvider.Users(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &timetrackerv1.ListUsersResponse{
+		Users:      make([]*timetrackerv1.User, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+		Total:      int32(page.Total),
+	}
+	for _, u := range page.Users {
+		resp.Users = append(resp.Users, toUser(u))
+	}
+	return resp, nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *timetrackerv1.GetUserRequest) (*timetrackerv1.GetUserResponse, error) {
+	user, err := s.service.UserProvider.UserById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.GetUserResponse{User: toUser(user)}, nil
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *timetrackerv1.CreateUserRequest) (*timetrackerv1.CreateUserResponse, error) {
+	input := models.InputUserCreate{PassportNumber: req.GetPassportNumber()}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	id, err := s.service.UserProvider.Create(ctx, input.PassportNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.CreateUserResponse{Id: int64(id)}, nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *timetrackerv1.UpdateUserRequest) (*timetrackerv1.UpdateUserResponse, error) {
+	input := models.UpdateUserInput{
+		PassportNumber: req.PassportNumber,
+		Name:           req.Name,
+		Surname:        req.Surname,
+		Patronymic:     req.Patronymic,
+		Address:        req.Address,
+		Version:        int(req.GetVersion()),
+	}
+	if err := validate(input); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.service.UserProvider.Update(ctx, input, int(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.UpdateUserResponse{User: toUser(updated)}, nil
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *timetrackerv1.DeleteUserRequest) (*timetrackerv1.DeleteUserResponse, error) {
+	if err := s.service.UserProvider.Delete(ctx, int(req.GetId()), int(req.GetVersion())); err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.DeleteUserResponse{}, nil
+}
+
+func (s *userServer) RestoreUser(ctx context.Context, req *timetrackerv1.RestoreUserRequest) (*timetrackerv1.RestoreUserResponse, error) {
+	if err := s.service.UserProvider.Restore(ctx, int(req.GetId())); err != nil {
+		return nil, err
+	}
+
+	return &timetrackerv1.RestoreUserResponse{}, nil
+}
+
+func toUser(u models.User) *timetrackerv1.User {
+	return &timetrackerv1.User{
+		Id:             int64(u.ID),
+		PassportNumber: u.PassportNumber,
+		Name:           u.Name,
+		Surname:        u.Surname,
+		Patronymic:     u.Patronymic,
+		Address:        u.Address,
+		Version:        int32(u.Version),
+	}
+}
@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"context"
+	timetrackerv1 "github.com/3XBAT/time-tracker/api/timetracker/v1"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"testing"
+)
+
+type fakeUsers struct {
+	service.UserProvider
+	params models.QueryParams
+}
+
+func (f *fakeUsers) Users(_ context.Context, params models.QueryParams) (models.UserPage, error) {
+	f.params = params
+	return models.UserPage{}, nil
+}
+
+func TestListUsersLimit(t *testing.T) {
+	tests := []struct {
+		limit int32
+		want  int
+	}{
+		{limit: 0, want: models.DefaultPageSize},
+		{limit: 20, want: 20},
+	}
+
+	for _, tt := range tests {
+		users := &fakeUsers{}
+		s := &userServer{service: &service.Service{UserProvider: users}}
+
+		if _, err := s.ListUsers(context.Background(), &timetrackerv1.ListUsersRequest{Limit: tt.limit}); err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if users.params.Limit != tt.want {
+			t.Errorf("limit %d: service got %d, want %d", tt.limit, users.params.Limit, tt.want)
+		}
+	}
+}
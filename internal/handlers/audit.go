@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetAuditEvents godoc
+// @Summary GetAuditEvents
+// @Tags Audit
+// @Description Returns changes of users and tasks, newest first. Changes are attributed to the X-Actor header of the request that made them
+// @Produce json
+// @Param actor query string false "Actor"
+// @Param action query string false "create, update, delete or restore"
+// @Param entity query string false "user or task"
+// @Param entity_id query int false "Entity ID"
+// @Param from query string false "Changes made at or after" format(date-time)
+// @Param to query string false "Changes made before" format(date-time)
+// @Param limit query int false "Page size, 50 by default"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.AuditPage
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/audit [get]
+func (h *Handler) getAuditEvents(c *gin.Context) {
+	var filter models.AuditFilter
+
+	fields, err := bindQuery(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	page, err := h.service.AuditProvider.Events(c.Request.Context(), filter)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The unversioned API is deprecated in favour of /api/v1 and is served
+// until legacySunset.
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated marks the responses of a legacy route with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers and links the route replacing
+// it, with the path parameters of the request filled in.
+func deprecated(successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(legacyDeprecatedAt.Unix(), 10)
+	sunset := legacySunset.Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		link := successor
+		for _, p := range c.Params {
+			link = strings.ReplaceAll(link, ":"+p.Key, url.PathEscape(p.Value))
+		}
+
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", "<"+link+`>; rel="successor-version"`)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// anyVersion is the version passed down for "If-Match: *".
+const anyVersion = 0
+
+var errPreconditionRequired = errors.New("If-Match header is required")
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// notModified answers a conditional GET with 304 when the client already
+// has the current version, otherwise it sets the ETag of the response.
+func notModified(c *gin.Context, version int) bool {
+	tag := etag(version)
+	c.Header("ETag", tag)
+
+	for _, t := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == tag || t == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch returns the version required by the If-Match header of a request
+// changing a record. Versions are compared strongly, so weak tags are refused.
+func ifMatch(c *gin.Context) (int, error) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" {
+		return 0, errPreconditionRequired
+	}
+	if h == "*" {
+		return anyVersion, nil
+	}
+
+	v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(h, `"`), `"`))
+	if err != nil || v <= 0 || h != etag(v) {
+		return 0, invalidInput("invalid If-Match header: %s, expected an ETag returned by the API", h)
+	}
+	return v, nil
+}
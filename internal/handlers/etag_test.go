@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		status  int
+		version int
+	}{
+		{name: "strong", header: []string{"If-Match", `"3"`}, status: http.StatusOK, version: 3},
+		{name: "padded", header: []string{"If-Match", ` "3" `}, status: http.StatusOK, version: 3},
+		{name: "any", header: []string{"If-Match", "*"}, status: http.StatusOK, version: anyVersion},
+		{name: "missing", status: http.StatusPreconditionRequired},
+		{name: "blank", header: []string{"If-Match", " "}, status: http.StatusPreconditionRequired},
+		{name: "weak", header: []string{"If-Match", `W/"3"`}, status: http.StatusBadRequest},
+		{name: "unquoted", header: []string{"If-Match", "3"}, status: http.StatusBadRequest},
+		{name: "not a version", header: []string{"If-Match", `"abc"`}, status: http.StatusBadRequest},
+		{name: "zero", header: []string{"If-Match", `"0"`}, status: http.StatusBadRequest},
+		{name: "negative", header: []string{"If-Match", `"-1"`}, status: http.StatusBadRequest},
+		{name: "list", header: []string{"If-Match", `"3", "4"`}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, router := newTestRouter(nil)
+			router.PUT("/", func(c *gin.Context) {
+				v, err := ifMatch(c)
+				if err != nil {
+					newErrorResponse(c, err)
+					return
+				}
+				c.String(http.StatusOK, strconv.Itoa(v))
+			})
+
+			w := serve(router, http.MethodPut, "/", "", tt.header...)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != strconv.Itoa(tt.version) {
+				t.Fatalf("version = %s, want %d", w.Body, tt.version)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		status int
+	}{
+		{name: "missing", status: http.StatusOK},
+		{name: "current", header: []string{"If-None-Match", `"3"`}, status: http.StatusNotModified},
+		{name: "weak", header: []string{"If-None-Match", `W/"3"`}, status: http.StatusNotModified},
+		{name: "any", header: []string{"If-None-Match", "*"}, status: http.StatusNotModified},
+		{name: "in a list", header: []string{"If-None-Match", `"1", W/"3"`}, status: http.StatusNotModified},
+		{name: "outdated", header: []string{"If-None-Match", `"2"`}, status: http.StatusOK},
+		{name: "invalid", header: []string{"If-None-Match", "3"}, status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, router := newTestRouter(nil)
+			router.GET("/", func(c *gin.Context) {
+				if notModified(c, 3) {
+					return
+				}
+				c.String(http.StatusOK, "body")
+			})
+
+			w := serve(router, http.MethodGet, "/", "", tt.header...)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("ETag"); got != `"3"` {
+				t.Fatalf("ETag = %s, want \"3\"", got)
+			}
+			if tt.status == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Fatalf("304 with a body: %s", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const lastEventIDHeader = "Last-Event-ID"
+
+// StreamEvents godoc
+// @Summary StreamEvents
+// @Tags Events
+// @Description Streams the changes of tasks and users as Server-Sent Events, each named by its type and carrying the event as JSON. Clients resuming with the Last-Event-ID header first get the events they missed, as far as they are still kept. A comment is sent as a heartbeat while nothing happens. Every instance streams all the changes, read from the outbox on every poll interval, starting with the ones made after it started. Nothing is streamed when the bus outbox sink is off
+// @Produce text/event-stream
+// @Param user_id query []int false "Only the events of these users. Filtering by team is not supported, teams are not part of the data model: pass the ids of the members instead, team and team_id are rejected" collectionFormat(multi)
+// @Param type query []string false "Only the events of these types: task.started, task.stopped, task.updated, task.deleted, task.restored, user.created, user.updated, user.deleted or user.restored" collectionFormat(multi)
+// @Param Last-Event-ID header int false "ID of the last event received"
+// @Success 200 {object} models.Event
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 422 {object} problem "Validation Failed"
+// @Router /api/v1/events [get]
+func (h *Handler) streamEvents(c *gin.Context) {
+	// there are no teams to filter by, ignoring the parameter would stream
+	// the events of every user
+	for _, param := range []string{"team", "team_id"} {
+		if _, ok := c.GetQuery(param); ok {
+			newErrorResponse(c, invalidInput("%s is not supported, teams are not part of the data model: pass the user_id of every member", param))
+			return
+		}
+	}
+
+	var filter models.EventFilter
+
+	fields, err := bindQuery(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if id := c.GetHeader(lastEventIDHeader); id != "" {
+		if filter.LastEventID, err = strconv.ParseUint(id, 10, 64); err != nil {
+			newErrorResponse(c, invalidInput("invalid %s header, expected an event id", lastEventIDHeader))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	log := logger.FromContext(ctx, h.log)
+
+	// the server timeouts are meant for regular requests, a stream stays
+	// open until the client or the bus ends it
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		log.Warn("failed to lift read deadline of event stream", slog.String("error", err.Error()))
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("failed to lift write deadline of event stream", slog.String("error", err.Error()))
+	}
+
+	events := h.service.EventProvider.Subscribe(ctx, filter)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.events.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(event.ID, 10),
+				Event: event.Type,
+				Data:  event,
+			})
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStreamEventsRejectsFilters(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{query: "?team=backend", status: http.StatusBadRequest},
+		{query: "?user_id=1&team_id=3", status: http.StatusBadRequest},
+		{query: "?user_id=abc", status: http.StatusBadRequest},
+		{query: "?user_id=0", status: http.StatusUnprocessableEntity},
+		{query: "?type=task.created", status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			// rejected streams never reach the services
+			h, router := newTestRouter(nil)
+			router.GET("/events", h.streamEvents)
+
+			w := serve(router, http.MethodGet, "/events"+tt.query, "")
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
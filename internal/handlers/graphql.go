@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/3XBAT/time-tracker/internal/graphqlapi"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ExecGraphQL godoc
+// @Summary ExecGraphQL
+// @Tags GraphQL
+// @Description Runs a GraphQL query over users, their tasks and the time spent on them, see internal/graphqlapi/schema.graphql for the schema. Errors of the query are reported in the errors member of a 200 response
+// @Accept json
+// @Produce json
+// @Param request body graphqlapi.Request true "Query, operation name and variables"
+// @Success 200 {object} map[string]interface{} "{"data": {}, "errors": []}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /graphql [post]
+func (h *Handler) execGraphQL(c *gin.Context) {
+	var req graphqlapi.Request
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		newErrorResponse(c, invalidInput("invalid request: expected a JSON object with a query"))
+		return
+	}
+
+	c.JSON(http.StatusOK, h.graphql.Exec(c.Request.Context(), req))
+}
@@ -2,38 +2,135 @@ package handlers
 
 import (
 	"github.com/3XBAT/time-tracker/docs"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/graphqlapi"
+	"github.com/3XBAT/time-tracker/internal/health"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/metrics"
 	"github.com/3XBAT/time-tracker/internal/service"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log/slog"
 	"net/http"
 )
 
+const serviceName = "time-tracker"
+
 type Handler struct {
-	service *service.Service
+	service  *service.Service
+	metrics  *metrics.Metrics
+	health   *health.Checker
+	features config.FeaturesConfig
+	events   config.EventsConfig
+	graphql  *graphqlapi.API
+	log      *slog.Logger
 }
 
-func NewHandler(service *service.Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(
+	log *slog.Logger,
+	service *service.Service,
+	metrics *metrics.Metrics,
+	health *health.Checker,
+	features config.FeaturesConfig,
+	events config.EventsConfig,
+) *Handler {
+	return &Handler{
+		service:  service,
+		metrics:  metrics,
+		health:   health,
+		features: features,
+		events:   events,
+		graphql:  graphqlapi.New(service, log),
+		log:      log,
+	}
 }
 
 func (h *Handler) InitRoutes() *gin.Engine {
 	router := gin.New()
-	docs.SwaggerInfo.BasePath = "/"
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.Use(
+		otelgin.Middleware(serviceName),
+		h.assignRequestID,
+		h.logRequests,
+		h.observeRequests,
+		h.idempotent,
+		h.handleErrors,
+		h.recoverPanics,
+	)
+
+	if h.features.Swagger {
+		docs.SwaggerInfo.BasePath = "/"
+		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	}
 
 	router.GET("/health", h.healthCheck)
+	router.GET("/livez", h.liveness)
+	router.GET("/readyz", h.readiness)
+	if h.features.Metrics {
+		router.GET("/metrics", gin.WrapH(h.metrics.Handler()))
+	}
+
+	router.POST("/graphql", h.execGraphQL)
+
+	v1 := router.Group("/api/v1")
+	v1.GET("/users", h.getUsers)
+	v1.POST("/users", h.createUser)
+	v1.GET("/users/:id", h.getUserByID)
+	v1.PUT("/users/:id", h.updateUser)
+	v1.PATCH("/users/:id", h.patchUser)
+	v1.DELETE("/users/:id", h.deleteUser)
+	v1.POST("/users/:id/restore", h.restoreUser)
+	v1.GET("/users/:id/export", h.exportUser)
+	v1.POST("/users/:id/erase", h.eraseUser)
+
+	v1.GET("/tasks", h.getTasks)
+	v1.POST("/tasks", h.createTask)
+	v1.POST("/tasks/batch", h.batchTasks)
+	v1.GET("/tasks/:id", h.getTaskByID)
+	v1.PATCH("/tasks/:id", h.patchTask)
+	v1.DELETE("/tasks/:id", h.deleteTaskByID)
+	v1.POST("/tasks/:id/stop", h.stopTask)
+	v1.POST("/tasks/:id/restore", h.restoreTask)
 
-	router.GET("/users", h.getUsers)
-	router.GET("/users/:id", h.getUserByID)
-	router.POST("/users", h.createUser)
-	router.PUT("/users/:id", h.updateUser)
-	router.DELETE("/users/:id", h.deleteUser)
+	v1.GET("/audit", h.getAuditEvents)
+	v1.GET("/events", h.streamEvents)
+
+	v1.GET("/webhooks", h.getWebhooks)
+	v1.POST("/webhooks", h.createWebhook)
+	v1.GET("/webhooks/:id", h.getWebhookByID)
+	v1.DELETE("/webhooks/:id", h.deleteWebhook)
+	v1.GET("/webhooks/:id/deliveries", h.getWebhookDeliveries)
+	v1.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.redeliverWebhook)
+
+	// the unversioned routes stay as aliases until the sunset of the legacy API
+	for _, r := range []struct {
+		method, path, successor string
+		handler                 gin.HandlerFunc
+	}{
+		{http.MethodGet, "/users", "/api/v1/users", h.getUsers},
+		{http.MethodGet, "/users/:id", "/api/v1/users/:id", h.getUserByID},
+		{http.MethodPost, "/users", "/api/v1/users", h.createUser},
+		{http.MethodPut, "/users/:id", "/api/v1/users/:id", h.updateUser},
+		{http.MethodPatch, "/users/:id", "/api/v1/users/:id", h.patchUser},
+		{http.MethodDelete, "/users/:id", "/api/v1/users/:id", h.deleteUser},
+		{http.MethodPost, "/users/:id/restore", "/api/v1/users/:id/restore", h.restoreUser},
+		{http.MethodGet, "/users/:id/export", "/api/v1/users/:id/export", h.exportUser},
+		{http.MethodPost, "/users/:id/erase", "/api/v1/users/:id/erase", h.eraseUser},
+
+		{http.MethodPost, "/tasks/", "/api/v1/tasks", h.createTask},
+		{http.MethodPost, "/tasks/batch", "/api/v1/tasks/batch", h.batchTasks},
+		{http.MethodGet, "/tasks/:id", "/api/v1/tasks/:id", h.getTaskByID},
+		{http.MethodPut, "/tasks/:id", "/api/v1/tasks/:id/stop", h.updateTask},
+		{http.MethodDelete, "/tasks/:id", "/api/v1/tasks/:id", h.deleteTask},
+		{http.MethodPost, "/tasks/:id/restore", "/api/v1/tasks/:id/restore", h.restoreTask},
+		{http.MethodGet, "/tasks/", "/api/v1/tasks", h.getTasks},
+
+		{http.MethodGet, "/audit", "/api/v1/audit", h.getAuditEvents},
+	} {
+		router.Handle(r.method, r.path, deprecated(r.successor), r.handler)
+	}
 
-	router.POST("/tasks/", h.createTask)
-	router.PUT("/tasks/:id", h.updateTask)    //
-	router.DELETE("/tasks/:id", h.deleteTask) //
-	router.GET("tasks/", h.getTasks)
 	return router
 }
 
@@ -49,3 +146,35 @@ func (h *Handler) healthCheck(c *gin.Context) {
 		"status": "service is available",
 	})
 }
+
+// @Summary Liveness probe
+// @Tags Service
+// @Description Reports that the process is running, without checking dependencies
+// @Produce json
+// @Success 200 {object} statusResponse
+// @Router /livez [get]
+func (h *Handler) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, statusResponse{Status: health.StatusOK})
+}
+
+// @Summary Readiness probe
+// @Tags Service
+// @Description Checks the database connection, pending migrations and the people info API
+// @Produce json
+// @Success 200 {object} health.Result
+// @Failure 503 {object} health.Result
+// @Router /readyz [get]
+func (h *Handler) readiness(c *gin.Context) {
+	res := h.health.Run(c.Request.Context())
+
+	if res.Status != health.StatusOK {
+		log := logger.FromContext(c.Request.Context(), h.log)
+		for name, err := range res.Errors {
+			log.Warn("readiness check failed", slog.String("check", name), slog.String("error", err.Error()))
+		}
+		c.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter returns a handler using the given services and a router
+// with the error handling of the API, the routes under test are added to it.
+func newTestRouter(svc *service.Service) (*Handler, *gin.Engine) {
+	h := &Handler{service: svc, log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	router := gin.New()
+	router.Use(h.handleErrors)
+	return h, router
+}
+
+// serve sends a request to the router, header holds pairs of names and values.
+func serve(router http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/3XBAT/time-tracker/internal/audit"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	replayedHeader       = "Idempotent-Replayed"
+	idempotencyUserKey   = "idempotency_user_id"
+)
+
+// replayedHeaders are the response headers stored along with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Deprecation", "Sunset", "Link"}
+
+// recordingWriter keeps a copy of the response body for the retries.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent lets clients safely retry a changing request by sending the
+// same Idempotency-Key header. The first response is stored and replayed
+// to the retries, a retry with another method, path, query or body is
+// refused.
+// Requests failing with a server error are not stored, so that they can be
+// retried. Keys are scoped to the actor making the request.
+func (h *Handler) idempotent(c *gin.Context) {
+	k := c.GetHeader(idempotencyKeyHeader)
+	if k == "" || c.FullPath() == "" {
+		c.Next()
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		c.Next()
+		return
+	}
+
+	if !logger.ValidRequestID(k) {
+		h.writeProblem(c, invalidInput("invalid %s header, expected up to %d printable characters", idempotencyKeyHeader, logger.MaxRequestIDLen))
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.writeProblem(c, invalidInput("failed to read request body"))
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx := c.Request.Context()
+	key, err := h.service.IdempotencyProvider.Begin(ctx, models.IdempotencyKey{
+		Actor:       audit.OriginFrom(ctx).Actor,
+		Key:         k,
+		RequestHash: requestHash(c, body),
+	})
+	if err != nil {
+		h.writeProblem(c, err)
+		c.Abort()
+		return
+	}
+
+	if key.StatusCode != 0 {
+		for name, value := range key.Header {
+			c.Header(name, value)
+		}
+		c.Header(replayedHeader, "true")
+		c.Status(key.StatusCode)
+		_, _ = c.Writer.Write(key.Body)
+		c.Abort()
+		return
+	}
+
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+
+	c.Next()
+
+	// the response must be stored even when the client has gone away,
+	// that is when it needs it the most
+	ctx = context.WithoutCancel(ctx)
+	if w.Status() >= http.StatusInternalServerError {
+		_ = h.service.IdempotencyProvider.Release(ctx, key)
+		return
+	}
+
+	key.StatusCode = w.Status()
+	key.Header = make(map[string]string)
+	for _, name := range replayedHeaders {
+		if v := w.Header().Get(name); v != "" {
+			key.Header[name] = v
+		}
+	}
+	key.Body = w.body.Bytes()
+	key.UserID = c.GetInt(idempotencyUserKey)
+	_ = h.service.IdempotencyProvider.Complete(ctx, key)
+}
+
+// carriesUserData marks a request as carrying the personal data of the user,
+// in its query, body or response. Its stored response is removed when the
+// user is erased.
+func carriesUserData(c *gin.Context, userID int) {
+	c.Set(idempotencyUserKey, userID)
+}
+
+// requestHash identifies the request a key was first used for.
+func requestHash(c *gin.Context, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, c.GetHeader("If-Match")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
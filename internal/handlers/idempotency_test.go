@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memKeys keeps idempotency keys in memory, they never expire.
+type memKeys struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotencyKey
+}
+
+func (m *memKeys) Reserve(_ context.Context, key models.IdempotencyKey, _, _ time.Time) (models.IdempotencyKey, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.keys[key.Actor+"/"+key.Key]; ok {
+		return stored, false, nil
+	}
+	m.keys[key.Actor+"/"+key.Key] = key
+	return key, true, nil
+}
+
+func (m *memKeys) Complete(_ context.Context, key models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[key.Actor+"/"+key.Key] = key
+	return nil
+}
+
+func (m *memKeys) Release(_ context.Context, key models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key.Actor+"/"+key.Key)
+	return nil
+}
+
+func (m *memKeys) PurgeUser(context.Context, int) error {
+	return nil
+}
+
+func (m *memKeys) Purge(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+// newIdempotentRouter serves POST /users, answering with the passport of
+// the query and the body, through the idempotency middleware.
+func newIdempotentRouter(handle func(c *gin.Context)) *gin.Engine {
+	keys := service.NewIdempotencyService(&memKeys{keys: make(map[string]models.IdempotencyKey)},
+		config.IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	h, router := newTestRouter(&service.Service{IdempotencyProvider: keys})
+	router.Use(h.idempotent)
+	router.POST("/users", handle)
+	return router
+}
+
+func TestIdempotentReplaysAndRefusesReuse(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, c.Query("PassportNumber")+string(body))
+	})
+
+	first := serve(router, http.MethodPost, "/users?PassportNumber=1234+567890", "", idempotencyKeyHeader, "k1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", first.Code)
+	}
+
+	retry := serve(router, http.MethodPost, "/users?PassportNumber=1234+567890", "", idempotencyKeyHeader, "k1")
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get(replayedHeader) != "true" {
+		t.Fatalf("retry = %d %q, want the first response replayed", retry.Code, retry.Body)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{name: "another query", target: "/users?PassportNumber=9999+000000"},
+		{name: "no query", target: "/users"},
+		{name: "another body", target: "/users?PassportNumber=1234+567890", body: `{"name":"Ivan"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodPost, tt.target, tt.body, idempotencyKeyHeader, "k1")
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+
+	if w := serve(router, http.MethodPost, "/users?PassportNumber=9999+000000", "", idempotencyKeyHeader, "k2"); w.Code != http.StatusCreated {
+		t.Fatalf("status with a new key = %d, want 201", w.Code)
+	}
+}
+
+func TestIdempotentRefusesRetryInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	router := newIdempotentRouter(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	done := make(chan int)
+	go func() {
+		done <- serve(router, http.MethodPost, "/users?PassportNumber=1234+567890", "", idempotencyKeyHeader, "k1").Code
+	}()
+	<-started
+
+	w := serve(router, http.MethodPost, "/users?PassportNumber=1234+567890", "", idempotencyKeyHeader, "k1")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status during the first request = %d, want 409: %s", w.Code, w.Body)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Fatalf("first request status = %d, want 201", code)
+	}
+}
+
+func TestIdempotentReleasesFailedRequests(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	if w := serve(router, http.MethodPost, "/users", "", idempotencyKeyHeader, "k1"); w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", w.Code)
+	}
+	if w := serve(router, http.MethodPost, "/users", "", idempotencyKeyHeader, "k1"); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("retry after a server error = %d after %d calls, want it run again", w.Code, calls)
+	}
+}
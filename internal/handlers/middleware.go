@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/audit"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+	actorHeader     = "X-Actor"
+)
+
+// assignRequestID takes the request id from the X-Request-ID header or
+// generates one, echoes it back and stores a logger annotated with it in
+// the request context for the handlers and services down the chain. The
+// request id and the X-Actor header also attribute the changes made by the
+// request in the audit log.
+func (h *Handler) assignRequestID(c *gin.Context) {
+	id := requestID(c)
+
+	log := h.log.With(slog.String("request_id", id))
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+		log = log.With(slog.String("trace_id", sc.TraceID().String()))
+	}
+
+	ctx := logger.WithContext(c.Request.Context(), log)
+	ctx = audit.WithOrigin(ctx, audit.Origin{Actor: actor(c), RequestID: id})
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+}
+
+// logRequests writes one access log line per request.
+func (h *Handler) logRequests(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	} else if status >= http.StatusBadRequest {
+		level = slog.LevelWarn
+	}
+
+	logger.FromContext(c.Request.Context(), h.log).LogAttrs(c.Request.Context(), level, "request completed",
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("route", c.FullPath()),
+		slog.Int("status", status),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int("bytes", c.Writer.Size()),
+		slog.String("client_ip", c.ClientIP()),
+		slog.String("user_agent", c.Request.UserAgent()),
+	)
+}
+
+// observeRequests records the count and latency of every request by route
+// template, so that path parameters do not blow up label cardinality.
+func (h *Handler) observeRequests(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	h.metrics.ObserveHTTP(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
+
+// recoverPanics turns a panic in a handler into an internal error, which
+// handleErrors then reports as a 500 problem response.
+func (h *Handler) recoverPanics(c *gin.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.FromContext(c.Request.Context(), h.log).Error("recovered from panic",
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())),
+			)
+			newErrorResponse(c, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	c.Next()
+}
+
+// requestID returns the id of the current request, taking it from the
+// X-Request-ID header or generating a new one.
+func requestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+
+	id := c.GetHeader(requestIDHeader)
+	if !logger.ValidRequestID(id) {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	return id
+}
+
+// actor names the client making the request, clients that do not identify
+// themselves are recorded as anonymous.
+func actor(c *gin.Context) string {
+	if a := c.GetHeader(actorHeader); logger.ValidRequestID(a) {
+		return a
+	}
+	return "anonymous"
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatZIP  = "zip"
+)
+
+// ExportUser godoc
+// @Summary ExportUser
+// @Tags Privacy
+// @Description Returns all personal data held about a user and all their tasks, including deleted ones, as JSON or as a ZIP archive with user.json and tasks.json
+// @Produce json
+// @Produce application/zip
+// @Param id path int true "User ID"
+// @Param format query string false "json (default) or zip"
+// @Success 200 {object} models.UserExport
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id}/export [get]
+func (h *Handler) exportUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
+		return
+	}
+
+	format := c.DefaultQuery("format", exportFormatJSON)
+	if format != exportFormatJSON && format != exportFormatZIP {
+		newErrorResponse(c, invalidInput("invalid format: %s, must be json or zip", format))
+		return
+	}
+
+	export, err := h.service.PrivacyProvider.Export(c.Request.Context(), id)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	name := fmt.Sprintf("user-%d-export", id)
+
+	if format == exportFormatJSON {
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	for _, f := range []struct {
+		name    string
+		content any
+	}{
+		{"user.json", export.User},
+		{"tasks.json", export.Tasks},
+	} {
+		w, err := zw.Create(f.name)
+		if err == nil {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(f.content)
+		}
+		if err != nil {
+			// the status is already sent, the client gets a truncated archive
+			_ = c.Error(err)
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// EraseUser godoc
+// @Summary EraseUser
+// @Tags Privacy
+// @Description Anonymizes the passport number, name fields and address of a user, also in the audit log. Tasks are kept, so that tracked time stays in reports
+// @Produce json
+// @Param id path int true "User ID"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} map[string]int "{"id": 1}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id}/erase [post]
+func (h *Handler) eraseUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
+		return
+	}
+
+	if err = h.service.PrivacyProvider.Erase(c.Request.Context(), id); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
@@ -1,14 +1,170 @@
 package handlers
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/api"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
 
-type errorResponse struct {
-	Message string `json:"message"`
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:time-tracker:problem:"
+)
+
+// problem is an RFC 7807 problem details document.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
 }
+
 type statusResponse struct {
 	Status string `json:"status"`
 }
 
-func newErrorResponse(c *gin.Context, status int, message string) {
-	c.AbortWithStatusJSON(status, errorResponse{message})
+// errorKind describes how a domain error is presented to clients.
+type errorKind struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+var errorKinds = []errorKind{
+	{storage.ErrUserNotFound, http.StatusNotFound, "user_not_found", "User not found"},
+	{storage.ErrTaskNotFound, http.StatusNotFound, "task_not_found", "Task not found"},
+	{storage.ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found", "Webhook not found"},
+	{storage.ErrDeliveryNotFound, http.StatusNotFound, "delivery_not_found", "Webhook delivery not found"},
+	{storage.ErrTaskEnded, http.StatusConflict, "task_already_finished", "Task already finished"},
+	{storage.ErrTaskRunning, http.StatusConflict, "task_running", "Task is still running, stop it first"},
+	{storage.ErrInvalidPeriod, http.StatusUnprocessableEntity, "invalid_period", "Task would end before it starts"},
+	{storage.ErrUserExists, http.StatusConflict, "user_already_exists", "User already exists"},
+	{storage.ErrInvalidSort, http.StatusBadRequest, "invalid_sort", "Invalid sort"},
+	{storage.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter", "Invalid filter"},
+	{storage.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor", "Invalid cursor"},
+	{storage.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", "Record was changed by someone else"},
+	{storage.ErrNothingToUpdate, http.StatusUnprocessableEntity, "empty_update", "No fields to update"},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported media type"},
+	{errPreconditionRequired, http.StatusPreconditionRequired, "precondition_required", "If-Match header is required"},
+	{service.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency key was used for a different request"},
+	{service.ErrRequestInProgress, http.StatusConflict, "request_in_progress", "Request with the same idempotency key is in progress"},
+	{storage.ErrBadRequest, http.StatusBadRequest, "bad_request", "Bad request"},
+	{service.ErrInvalidPassport, http.StatusBadRequest, "invalid_passport", "Invalid passport number"},
+	{api.ErrPersonNotFound, http.StatusNotFound, "person_not_found", "Person not found in the people info service"},
+	{api.ErrInvalidPassport, http.StatusBadRequest, "invalid_passport", "Passport rejected by the people info service"},
+	{api.ErrUnavailable, http.StatusBadGateway, "upstream_unavailable", "People info service unavailable"},
+}
+
+var internalErrorKind = errorKind{
+	status: http.StatusInternalServerError,
+	code:   "internal_error",
+	title:  "Internal server error",
+}
+
+// inputError is returned by handlers for malformed requests. Unlike
+// domain errors its message is safe to show to the client.
+type inputError struct {
+	msg string
+}
+
+func (e *inputError) Error() string {
+	return e.msg
+}
+
+func invalidInput(format string, args ...any) error {
+	return &inputError{msg: fmt.Sprintf(format, args...)}
+}
+
+// newErrorResponse records err on the context and aborts the chain,
+// the response itself is written by handleErrors.
+func newErrorResponse(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// handleErrors converts the last error recorded by a handler into a
+// problem response. Internal details are logged and never sent to clients.
+func (h *Handler) handleErrors(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	h.writeProblem(c, c.Errors.Last().Err)
+}
+
+// writeProblem sends err to the client as a problem response.
+func (h *Handler) writeProblem(c *gin.Context, err error) {
+	p := newProblem(err)
+	p.Instance = c.Request.URL.Path
+	p.RequestID = requestID(c)
+
+	log := logger.FromContext(c.Request.Context(), h.log).With(slog.String("code", p.Code))
+	if p.Status >= http.StatusInternalServerError {
+		log.Error("request failed", slog.String("error", err.Error()))
+	} else {
+		log.Info("request rejected", slog.String("error", err.Error()))
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.JSON(p.Status, p)
+}
+
+func newProblem(err error) problem {
+	var valErr *validationError
+	if errors.As(err, &valErr) {
+		return problem{
+			Type:   problemTypePrefix + "validation_failed",
+			Title:  "Validation failed",
+			Status: http.StatusUnprocessableEntity,
+			Detail: "one or more fields are invalid",
+			Code:   "validation_failed",
+			Errors: valErr.fields,
+		}
+	}
+
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		p := newProblem(batchErr.Err)
+		p.Detail = fmt.Sprintf("operation %d failed, no operations were applied", batchErr.Index)
+		return p
+	}
+
+	var inErr *inputError
+	if errors.As(err, &inErr) {
+		return problem{
+			Type:   problemTypePrefix + "invalid_input",
+			Title:  "Invalid input",
+			Status: http.StatusBadRequest,
+			Detail: inErr.msg,
+			Code:   "invalid_input",
+		}
+	}
+
+	kind := internalErrorKind
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			kind = k
+			break
+		}
+	}
+
+	return problem{
+		Type:   problemTypePrefix + kind.code,
+		Title:  kind.title,
+		Status: kind.status,
+		Code:   kind.code,
+	}
 }
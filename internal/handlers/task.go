@@ -1,12 +1,8 @@
 package handlers
 
 import (
-	"errors"
 	"fmt"
-	"time"
-
 	"github.com/3XBAT/time-tracker/internal/domain/models"
-	"github.com/3XBAT/time-tracker/internal/storage"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -19,21 +15,32 @@ import (
 // @Accept json
 // @Produce json
 // @Param input body models.InputTaskCreate true "task info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /tasks/ [post]
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks [post]
 func (h *Handler) createTask(c *gin.Context) {
 	var input models.InputTaskCreate
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	fields, err := bindJSON(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if fields, err = h.checkUserExists(c.Request.Context(), fields, "user_id", input.UserID); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	taskId, err := h.service.TaskProvider.Create(input)
+	taskId, err := h.service.TaskProvider.Create(c.Request.Context(), input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, err)
 		return
 	}
 
@@ -42,44 +49,142 @@ func (h *Handler) createTask(c *gin.Context) {
 	})
 }
 
+// @Summary GetTaskByID
+// @Tags Task
+// @Description Returns a task by ID with its version in the ETag header
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "Version of the task"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/{id} [get]
+func (h *Handler) getTaskByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid task id: %s", c.Param("id")))
+		return
+	}
+
+	task, err := h.service.TaskProvider.TaskById(c.Request.Context(), id)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, task.Version) {
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// @Summary PatchTask
+// @Tags Task
+// @Description Change the name, start_time or end_time of a task with a JSON Merge Patch (RFC 7396). Members that are left out
+// @Description stay unchanged, fields can not be removed with null. end_time can only be changed once the task is finished
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param If-Match header string true "ETag of the task being changed, or *"
+// @Param patch body models.InputTaskPatch true "Fields to change"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "New version of the task"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 409 {object} problem "Task Still Running"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 415 {object} problem "Unsupported Media Type"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/{id} [patch]
+func (h *Handler) patchTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid task id: %s", c.Param("id")))
+		return
+	}
+
+	var patch models.InputTaskPatch
+
+	if patch.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	fields, err := bindMergePatch(c, &patch)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	updated, err := h.service.TaskProvider.Patch(c.Request.Context(), id, patch)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, updated)
+}
+
 // @Summary UpdateTask
 // @Tags Task
-// @Description Update an existing task
+// @Description Finish a task, the id is read from the body. Deprecated, use POST /api/v1/tasks/{id}/stop
+// @Deprecated
 // @Accept json
 // @Produce json
+// @Param If-Match header string true "ETag of the task being changed, or *"
 // @Param input body models.InputTaskUpdate true "task update info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"task id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 404 {object} map[string]string "{"error": "Not Found"}"
-// @Failure 208 {object} map[string]string "{"error": "Task Already Ended"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
+// @Header 200 {string} ETag "New version of the task"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 409 {object} problem "Task Already Ended"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
 // @Router /tasks/{id} [put]
 func (h *Handler) updateTask(c *gin.Context) {
 	var input models.InputTaskUpdate
+	var err error
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if input.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	if _, err := h.service.UserProvider.UserById(input.UserID); err != nil {
-		newErrorResponse(c, http.StatusNotFound, err.Error())
+	fields, err := bindJSON(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if fields, err = h.checkUserExists(c.Request.Context(), fields, "user_id", input.UserID); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	if err := h.service.TaskProvider.Update(input); err != nil {
-		if errors.Is(err, storage.ErrTaskEnded) {
-			newErrorResponse(c, http.StatusAlreadyReported, err.Error())
-			return
-		}
-		if errors.Is(err, storage.ErrTaskNotFound) {
-			newErrorResponse(c, http.StatusNotFound, err.Error())
-			return
-		}
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	updated, err := h.service.TaskProvider.Update(c.Request.Context(), input)
+	if err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
+	c.Header("ETag", etag(updated.Version))
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"task id": input.Id,
 	})
@@ -88,35 +193,124 @@ func (h *Handler) updateTask(c *gin.Context) {
 
 // @Summary DeleteTask
 // @Tags Task
-// @Description Delete an existing task
+// @Description Delete a task, the id is read from the body. Deprecated, use DELETE /api/v1/tasks/{id}
+// @Deprecated
 // @Accept json
 // @Produce json
+// @Param If-Match header string true "ETag of the task being deleted, or *"
 // @Param input body models.InputTaskDelete true "task delete info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 404 {object} map[string]string "{"error": "Not Found"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
 // @Router /tasks/{id} [delete]
 func (h *Handler) deleteTask(c *gin.Context) {
 
 	var input models.InputTaskDelete
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, "error with body"+err.Error())
+	var err error
+
+	if input.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	if _, err := h.service.UserProvider.UserById(input.UserID); err != nil {
-		newErrorResponse(c, http.StatusNotFound, err.Error())
+	fields, err := bindJSON(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if fields, err = h.checkUserExists(c.Request.Context(), fields, "user_id", input.UserID); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
 	}
 
-	err := h.service.TaskProvider.Delete(input)
+	err = h.service.TaskProvider.Delete(c.Request.Context(), input)
 	if err != nil {
-		if errors.Is(err, storage.ErrTaskNotFound) {
-			newErrorResponse(c, http.StatusNotFound, err.Error())
-			return
-		}
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": input.TaskID,
+	})
+}
+
+// @Summary StopTask
+// @Tags Task
+// @Description Finish a running task by ID
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param If-Match header string true "ETag of the task being changed, or *"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "New version of the task"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 409 {object} problem "Task Already Ended"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/{id}/stop [post]
+func (h *Handler) stopTask(c *gin.Context) {
+	var input models.InputTaskUpdate
+	var err error
 
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if input.Id, err = strconv.Atoi(c.Param("id")); err != nil {
+		newErrorResponse(c, invalidInput("invalid task id: %s", c.Param("id")))
+		return
+	}
+	if input.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	updated, err := h.service.TaskProvider.Update(c.Request.Context(), input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, updated)
+}
+
+// @Summary DeleteTaskByID
+// @Tags Task
+// @Description Delete a task by ID, it can be restored until purged
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param If-Match header string true "ETag of the task being deleted, or *"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} map[string]int "{"id": 1}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/{id} [delete]
+func (h *Handler) deleteTaskByID(c *gin.Context) {
+	var input models.InputTaskDelete
+	var err error
+
+	if input.TaskID, err = strconv.Atoi(c.Param("id")); err != nil {
+		newErrorResponse(c, invalidInput("invalid task id: %s", c.Param("id")))
+		return
+	}
+	if input.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	if err = h.service.TaskProvider.Delete(c.Request.Context(), input); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
@@ -133,53 +327,165 @@ func (h *Handler) deleteTask(c *gin.Context) {
 // @Param user_id query int true "User ID"
 // @Param start_time query string false "Start Time" format(date-time)
 // @Param end_time query string false "End Time" format(date-time)
-// @Success 200 {object} map[string][]models.OutputTask "{"tasks": [...]}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /tasks [get]
+// @Param limit query int false "Page size, 50 by default"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "name, start_time or duration, optionally followed by :asc or :desc, duration:desc by default"
+// @Success 200 {object} models.TaskPage
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks [get]
 func (h *Handler) getTasks(c *gin.Context) {
-
 	var input models.InputTask
 
-	userID, err := strconv.Atoi(c.Query("user_id"))
+	fields, err := bindQuery(c, &input)
 	if err != nil {
-		newErrorResponse(c, http.StatusBadRequest, "invalid user ID"+err.Error())
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
-	input.UserID = userID
 
-	checkStartTime := c.Query("start_time")
+	page, err := h.service.TaskProvider.Tasks(c.Request.Context(), input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
 
-	if checkStartTime != "" {
-		startTime, err := time.Parse(time.RFC3339, checkStartTime)
-		if err != nil {
-			newErrorResponse(c, http.StatusBadRequest, "invalid start time"+err.Error())
+// @Summary RestoreTask
+// @Tags Task
+// @Description Restore a deleted task, tasks of deleted users are restored with the user
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} map[string]int "{"id": 1}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/{id}/restore [post]
+func (h *Handler) restoreTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid task id: %s", c.Param("id")))
+		return
+	}
+
+	if err = h.service.TaskProvider.Restore(c.Request.Context(), id); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// batchResult reports one operation of a batch. Status is the code the
+// operation would have got as a separate request.
+type batchResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	Status  int      `json:"status"`
+	TaskID  int      `json:"task_id,omitempty"`
+	Version int      `json:"version,omitempty"`
+	Error   *problem `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+// @Summary BatchTasks
+// @Tags Task
+// @Description Create, stop and delete many tasks in one call. Every operation is validated as the separate request would be,
+// @Description "version" takes the place of the If-Match header and may be omitted. An atomic batch is applied in one
+// @Description transaction and fails as a whole with the problem of the first failed operation, otherwise each operation
+// @Description succeeds or fails on its own and the results report the outcome of each.
+// @Accept json
+// @Produce json
+// @Param input body models.InputTaskBatch true "operations"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} batchResponse
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 409 {object} problem "Task Already Ended"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/tasks/batch [post]
+func (h *Handler) batchTasks(c *gin.Context) {
+	var input models.InputTaskBatch
+
+	fields, err := bindJSON(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]batchResult, len(input.Operations))
+	ops := make([]models.TaskOperation, 0, len(input.Operations))
+	indexes := make([]int, 0, len(input.Operations))
+	var invalid []fieldError
+
+	for i := range input.Operations {
+		op := input.Operations[i]
+		results[i] = batchResult{Index: i, Op: op.Op}
+
+		opFields := validateStruct(&op)
+		if opFields, err = h.checkUserExists(ctx, opFields, "user_id", op.UserID); err != nil {
+			newErrorResponse(c, err)
 			return
 		}
-		input.StartPeriod = &startTime
-	} else {
-		input.StartPeriod = nil
+		if len(opFields) > 0 {
+			results[i].fail(validationFailed(opFields))
+			for _, f := range opFields {
+				invalid = append(invalid, fieldError{Field: fmt.Sprintf("operations[%d].%s", i, f.Field), Message: f.Message})
+			}
+			continue
+		}
+
+		ops = append(ops, op)
+		indexes = append(indexes, i)
 	}
 
-	checkEndTime := c.Query("end_time")
-	if checkEndTime != "" {
-		endTime, err := time.Parse(time.RFC3339, c.Query("end_time"))
-		if err != nil {
-			newErrorResponse(c, http.StatusBadRequest, "invalid end time"+err.Error())
+	if input.Atomic {
+		if err = validationFailed(invalid); err != nil {
+			newErrorResponse(c, err)
 			return
 		}
-		input.EndPeriod = &endTime
-	} else {
-		input.EndPeriod = nil
 	}
 
-	fmt.Println(userID, checkEndTime, checkStartTime)
-	
-	tasks, err := h.service.TaskProvider.Tasks(input)
+	applied, err := h.service.TaskProvider.Batch(ctx, ops, input.Atomic)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	for j, res := range applied {
+		r := &results[indexes[j]]
+		r.TaskID, r.Version = res.TaskID, res.Version
+		if res.Err != nil {
+			r.fail(res.Err)
+			continue
+		}
+		r.Status = http.StatusOK
+	}
+
+	c.JSON(http.StatusOK, batchResponse{Results: results})
+}
+
+func (r *batchResult) fail(err error) {
+	p := newProblem(err)
+	r.Status = p.Status
+	r.Error = &p
 }
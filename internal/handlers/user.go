@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"errors"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
-	"github.com/3XBAT/time-tracker/internal/storage"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -12,7 +10,7 @@ import (
 // GetUsers godoc
 // @Summary GetUsers
 // @Tags User
-// @Description Returns users according to filters and pagination
+// @Description Returns users according to filters and pagination. Filter values match exactly unless prefixed with >, >=, <, <=, contains:, prefix: or in: (comma-separated list)
 // @Accept json
 // @Produce json
 // @Param ID query string false "user id"
@@ -21,78 +19,76 @@ import (
 // @Param Patronymic query string false "Patronymic"
 // @Param PassportNumber query string false "PassportNumber"
 // @Param Address query string false "Address"
-// @Param Limit query int false "Limit"
-// @Param Offset query int false "Offset"
-// @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 404 {object} map[string]string "{"error": "Not Found"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /users [get]
+// @Param Search query string false "Full-text search across name, surname, patronymic and address"
+// @Param Limit query int false "Page size, 50 by default"
+// @Param Cursor query string false "next_cursor of the previous page, paging by Offset is not supported"
+// @Param Sort query string false "id, name or surname, optionally followed by :asc or :desc"
+// @Success 200 {object} models.UserPage
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users [get]
 func (h *Handler) getUsers(c *gin.Context) {
+	// paging by offset was replaced by cursors, ignoring it would silently
+	// return the first page over and over
+	if _, ok := c.GetQuery("Offset"); ok {
+		newErrorResponse(c, invalidInput("Offset is no longer supported, pass next_cursor of the previous page as Cursor"))
+		return
+	}
+
 	var queryParams models.QueryParams
-	var err error
-
-	queryParams.ID = c.Query("ID")
-	queryParams.Name = c.Query("Name")
-	queryParams.Surname = c.Query("Surname")
-	queryParams.Patronymic = c.Query("Patronymic")
-	queryParams.PassportNumber = c.Query("PassportNumber")
-	queryParams.Address = c.Query("Address")
-
-	if queryParams.Limit, err = strconv.Atoi(c.Query("Limit")); err != nil {
-		if check := c.Query("Limit"); check == "" {
-			queryParams.Limit = 10
-		} else {
-			newErrorResponse(c, http.StatusBadRequest, "invalid Limit")
-			return
-		}
-	}
-	if queryParams.Offset, err = strconv.Atoi(c.Query("Offset")); err != nil {
-		if check := c.Query("Offset"); check == "" {
-			queryParams.Offset = 0
-		} else {
-			newErrorResponse(c, http.StatusBadRequest, "invalid Offset")
-			return
-		}
-	}
-
-	users, err := h.service.UserProvider.Users(queryParams)
-	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			newErrorResponse(c, http.StatusNotFound, err.Error())
-			return
-		}
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
-	}
-
-	c.JSON(http.StatusOK, users)
+
+	fields, err := bindQuery(c, &queryParams)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	page, err := h.service.UserProvider.Users(c.Request.Context(), queryParams)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 // GetUserByID godoc
 // @Summary GetUserByID
 // @Tags User
-// @Description Returns a user by ID
+// @Description Returns a user by ID with its version in the ETag header
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.User
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 404 {object} map[string]string "{"error": "Not Found"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /users/{id} [get]
+// @Header 200 {string} ETag "Version of the user"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id} [get]
 func (h *Handler) getUserByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
 		return
 	}
 
-	user, err := h.service.UserProvider.UserById(id)
+	user, err := h.service.UserProvider.UserById(c.Request.Context(), id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, err)
 		return
 	}
 
+	if notModified(c, user.Version) {
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
 
@@ -103,20 +99,34 @@ func (h *Handler) getUserByID(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param PassportNumber query string true "User info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /users [post]
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Person Not Found"
+// @Failure 409 {object} problem "User Already Exists"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Failure 502 {object} problem "People Info Service Unavailable"
+// @Router /api/v1/users [post]
 func (h *Handler) createUser(c *gin.Context) {
-	var userPassport string
+	var input models.InputUserCreate
 
-	userPassport = c.Query("PassportNumber")
+	fields, err := bindQuery(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
 
-	id, err := h.service.UserProvider.Create(userPassport)
+	id, err := h.service.UserProvider.Create(c.Request.Context(), input.PassportNumber)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, err)
 		return
 	}
+	carriesUserData(c, id)
 
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"id": id,
@@ -130,55 +140,142 @@ func (h *Handler) createUser(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user being changed, or *"
 // @Param user body models.UpdateUserInput true "User update info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /users/{id} [put]
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id} [put]
 func (h *Handler) updateUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
 		return
 	}
+	carriesUserData(c, id)
 
 	var user models.UpdateUserInput
-	if err = c.BindJSON(&user); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+
+	if user.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	if err = h.service.UserProvider.Update(user, id); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	fields, err := bindJSON(c, &user)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
+	updated, err := h.service.UserProvider.Update(c.Request.Context(), user, id)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"user_id": id,
 	})
 }
 
+// PatchUser godoc
+// @Summary PatchUser
+// @Tags User
+// @Description Change any editable field of a user with a JSON Merge Patch (RFC 7396). Members that are left out stay unchanged, fields can not be removed with null
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user being changed, or *"
+// @Param patch body models.UpdateUserInput true "Fields to change"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} models.User
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 415 {object} problem "Unsupported Media Type"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id} [patch]
+func (h *Handler) patchUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
+		return
+	}
+	carriesUserData(c, id)
+
+	var patch models.UpdateUserInput
+
+	if patch.Version, err = ifMatch(c); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	fields, err := bindMergePatch(c, &patch)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	updated, err := h.service.UserProvider.Update(c.Request.Context(), patch, id)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	c.JSON(http.StatusOK, updated)
+}
+
 // DeleteUser godoc
 // @Summary DeleteUser
 // @Tags User
-// @Description Delete a user by ID
+// @Description Delete a user and their tasks by ID, they can be restored until purged
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user being deleted, or *"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
 // @Success 200 {object} map[string]int "{"id": 1}"
-// @Failure 400 {object} map[string]string "{"error": "Bad Request"}"
-// @Failure 500 {object} map[string]string "{"error": "Internal Server Error"}"
-// @Router /users/{id} [delete]
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 412 {object} problem "Version Mismatch"
+// @Failure 428 {object} problem "If-Match Required"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id} [delete]
 func (h *Handler) deleteUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		newErrorResponse(c, err)
 		return
 	}
 
-	err = h.service.UserProvider.Delete(id)
+	err = h.service.UserProvider.Delete(c.Request.Context(), id, version)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -186,3 +283,32 @@ func (h *Handler) deleteUser(c *gin.Context) {
 	})
 
 }
+
+// RestoreUser godoc
+// @Summary RestoreUser
+// @Tags User
+// @Description Restore a deleted user together with the tasks deleted with them
+// @Produce json
+// @Param id path int true "User ID"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} map[string]int "{"id": 1}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/users/{id}/restore [post]
+func (h *Handler) restoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid user id: %s", c.Param("id")))
+		return
+	}
+
+	if err = h.service.UserProvider.Restore(c.Request.Context(), id); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
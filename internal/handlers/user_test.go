@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"net/http"
+	"testing"
+)
+
+type fakeUsers struct {
+	service.UserProvider
+	params *models.QueryParams
+}
+
+func (f *fakeUsers) Users(_ context.Context, params models.QueryParams) (models.UserPage, error) {
+	f.params = &params
+	return models.UserPage{Users: []models.User{}}, nil
+}
+
+func TestGetUsersPaging(t *testing.T) {
+	tests := []struct {
+		query  string
+		status int
+		limit  int
+	}{
+		{query: "", status: http.StatusOK, limit: models.DefaultPageSize},
+		{query: "?Limit=20&Cursor=abc", status: http.StatusOK, limit: 20},
+		{query: "?Limit=abc", status: http.StatusBadRequest},
+		{query: "?Limit=0", status: http.StatusUnprocessableEntity},
+		{query: "?Limit=101", status: http.StatusUnprocessableEntity},
+		{query: "?Offset=10", status: http.StatusBadRequest},
+		{query: "?Limit=10&Offset=0", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			users := &fakeUsers{}
+			h, router := newTestRouter(&service.Service{UserProvider: users})
+			router.GET("/users", h.getUsers)
+
+			w := serve(router, http.MethodGet, "/users"+tt.query, "")
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				if users.params != nil {
+					t.Fatal("rejected request reached the service")
+				}
+				return
+			}
+			if users.params == nil || users.params.Limit != tt.limit {
+				t.Fatalf("service got %+v, want limit %d", users.params, tt.limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/3XBAT/time-tracker/internal/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+var errUnsupportedMediaType = errors.New("unsupported content type")
+
+// fieldError describes a single input field that failed validation.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError carries every failed field of a request.
+type validationError struct {
+	fields []fieldError
+}
+
+func (e *validationError) Error() string {
+	msgs := make([]string, 0, len(e.fields))
+	for _, f := range e.fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// bindJSON decodes the body into input and runs its validation rules.
+// Failed rules are returned as field errors so they can be combined with
+// further checks, a malformed body is returned as an input error.
+func bindJSON(c *gin.Context, input any) ([]fieldError, error) {
+	return checkBinding(c.ShouldBindJSON(input))
+}
+
+// bindQuery is the query string counterpart of bindJSON.
+func bindQuery(c *gin.Context, input any) ([]fieldError, error) {
+	return checkBinding(c.ShouldBindQuery(input))
+}
+
+// bindMergePatch decodes an RFC 7396 JSON Merge Patch into input. Every
+// member must name a field of input, and since all of them are required
+// attributes removing one with null is reported as a field error as well.
+func bindMergePatch(c *gin.Context, input any) ([]fieldError, error) {
+	if c.ContentType() != mergePatchContentType {
+		return nil, errUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, invalidInput("invalid request: %s", err)
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return nil, invalidInput("invalid request: merge patch must be a JSON object")
+	}
+
+	known := make(map[string]bool)
+	t := reflect.TypeOf(input).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Tag.Get("json") != "-" {
+			known[validation.FieldName(f)] = true
+		}
+	}
+
+	var fields []fieldError
+	for name, value := range members {
+		switch {
+		case !known[name]:
+			fields = append(fields, fieldError{Field: name, Message: "is not an editable field"})
+		case string(value) == "null":
+			fields = append(fields, fieldError{Field: name, Message: "can not be removed"})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+
+	if err := json.Unmarshal(body, input); err != nil {
+		return nil, invalidInput("invalid request: %s", err)
+	}
+
+	return append(fields, validateStruct(input)...), nil
+}
+
+// validateStruct runs the validation rules of an already populated input.
+func validateStruct(input any) []fieldError {
+	fields, _ := checkBinding(validation.Struct(input))
+	return fields
+}
+
+func checkBinding(err error) ([]fieldError, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, invalidInput("invalid request: %s", err)
+	}
+
+	fields := make([]fieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, fieldError{
+			Field:   fe.Field(),
+			Message: validation.Message(fe),
+		})
+	}
+	return fields, nil
+}
+
+// checkUserExists adds a field error when userID does not reference an
+// existing user. It is skipped if the field has already failed validation.
+func (h *Handler) checkUserExists(ctx context.Context, fields []fieldError, field string, userID int) ([]fieldError, error) {
+	for _, f := range fields {
+		if f.Field == field {
+			return fields, nil
+		}
+	}
+
+	if _, err := h.service.UserProvider.UserById(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return append(fields, fieldError{Field: field, Message: "does not reference an existing user"}), nil
+		}
+		return fields, err
+	}
+
+	return fields, nil
+}
+
+// validationFailed turns the collected field errors into a single error,
+// or nil when there are none.
+func validationFailed(fields []fieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return &validationError{fields: fields}
+}
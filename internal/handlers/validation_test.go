@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/service"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// patchingUsers applies a patch like the storage does, refusing empty ones.
+type patchingUsers struct {
+	service.UserProvider
+	patch *models.UpdateUserInput
+}
+
+func (f *patchingUsers) Update(_ context.Context, patch models.UpdateUserInput, id int) (models.User, error) {
+	f.patch = &patch
+	if patch.PassportNumber == nil && patch.Name == nil && patch.Surname == nil && patch.Patronymic == nil && patch.Address == nil {
+		return models.User{}, fmt.Errorf("storage.user.Update: %w", storage.ErrNothingToUpdate)
+	}
+	return models.User{ID: id, Version: patch.Version + 1}, nil
+}
+
+func TestPatchUserMergePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		fields      []string
+	}{
+		{name: "one member", contentType: mergePatchContentType, body: `{"name":"Ivan"}`, status: http.StatusOK},
+		{name: "content type parameters", contentType: mergePatchContentType + "; charset=utf-8", body: `{"name":"Ivan"}`, status: http.StatusOK},
+		{name: "plain JSON", contentType: "application/json", body: `{"name":"Ivan"}`, status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "no content type", body: `{"name":"Ivan"}`, status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "empty patch", contentType: mergePatchContentType, body: `{}`, status: http.StatusUnprocessableEntity, code: "empty_update"},
+		{name: "null member", contentType: mergePatchContentType, body: `{"name":null}`, status: http.StatusUnprocessableEntity, code: "validation_failed", fields: []string{"name"}},
+		{name: "unknown member", contentType: mergePatchContentType, body: `{"name":"Ivan","age":30}`, status: http.StatusUnprocessableEntity, code: "validation_failed", fields: []string{"age"}},
+		{name: "version is not editable", contentType: mergePatchContentType, body: `{"Version":7}`, status: http.StatusUnprocessableEntity, code: "validation_failed", fields: []string{"Version"}},
+		{name: "every failure", contentType: mergePatchContentType, body: `{"surname":null,"age":30,"name":" "}`, status: http.StatusUnprocessableEntity, code: "validation_failed", fields: []string{"age", "surname", "name"}},
+		{name: "not an object", contentType: mergePatchContentType, body: `["name"]`, status: http.StatusBadRequest, code: "invalid_input"},
+		{name: "null patch", contentType: mergePatchContentType, body: `null`, status: http.StatusBadRequest, code: "invalid_input"},
+		{name: "malformed", contentType: mergePatchContentType, body: `{"name":`, status: http.StatusBadRequest, code: "invalid_input"},
+		{name: "wrong type", contentType: mergePatchContentType, body: `{"name":1}`, status: http.StatusBadRequest, code: "invalid_input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &patchingUsers{}
+			h, router := newTestRouter(&service.Service{UserProvider: users})
+			router.PATCH("/users/:id", h.patchUser)
+
+			header := []string{"If-Match", `"2"`}
+			if tt.contentType != "" {
+				header = append(header, "Content-Type", tt.contentType)
+			}
+			w := serve(router, http.MethodPatch, "/users/1", tt.body, header...)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				if users.patch == nil || users.patch.Name == nil || *users.patch.Name != "Ivan" || users.patch.Version != 2 {
+					t.Fatalf("service got %+v, want the name and version 2", users.patch)
+				}
+				if got := w.Header().Get("ETag"); got != `"3"` {
+					t.Fatalf("ETag = %s, want \"3\"", got)
+				}
+				return
+			}
+
+			var p problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+			if p.Code != tt.code {
+				t.Fatalf("code = %s, want %s", p.Code, tt.code)
+			}
+			var fields []string
+			for _, f := range p.Errors {
+				fields = append(fields, f.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Fatalf("invalid fields %v, want %v", fields, tt.fields)
+			}
+			if tt.code != "empty_update" && users.patch != nil {
+				t.Fatal("rejected patch reached the service")
+			}
+		})
+	}
+}
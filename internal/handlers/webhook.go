@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// CreateWebhook godoc
+// @Summary CreateWebhook
+// @Tags Webhook
+// @Description Subscribes an https URL to events of the given types, they are POSTed to it as JSON, only to public addresses. The secret signs the deliveries and is only returned here, a random one is generated when left out
+// @Accept json
+// @Produce json
+// @Param input body models.InputWebhookCreate true "webhook info"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks [post]
+func (h *Handler) createWebhook(c *gin.Context) {
+	var input models.InputWebhookCreate
+
+	fields, err := bindJSON(c, &input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	webhook, err := h.service.WebhookProvider.Create(c.Request.Context(), input)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// @Summary GetWebhooks
+// @Tags Webhook
+// @Description Returns every webhook, without the secrets
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks [get]
+func (h *Handler) getWebhooks(c *gin.Context) {
+	webhooks, err := h.service.WebhookProvider.Webhooks(c.Request.Context())
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// @Summary GetWebhookByID
+// @Tags Webhook
+// @Description Returns a webhook by ID, without the secret
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks/{id} [get]
+func (h *Handler) getWebhookByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid webhook id: %s", c.Param("id")))
+		return
+	}
+
+	webhook, err := h.service.WebhookProvider.Webhook(c.Request.Context(), id)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// @Summary DeleteWebhook
+// @Tags Webhook
+// @Description Deletes a webhook along with its pending deliveries and delivery logs
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} map[string]int "{"id": 1}"
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks/{id} [delete]
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid webhook id: %s", c.Param("id")))
+		return
+	}
+
+	if err = h.service.WebhookProvider.Delete(c.Request.Context(), id); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// @Summary GetWebhookDeliveries
+// @Tags Webhook
+// @Description Returns the deliveries of a webhook, newest first, each with the log of its attempts
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "pending, delivered or failed"
+// @Param limit query int false "Page size, 50 by default"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} models.WebhookDeliveryPage
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 422 {object} problem "Validation Failed"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *Handler) getWebhookDeliveries(c *gin.Context) {
+	var filter models.WebhookDeliveryFilter
+	var err error
+
+	if filter.WebhookID, err = strconv.Atoi(c.Param("id")); err != nil {
+		newErrorResponse(c, invalidInput("invalid webhook id: %s", c.Param("id")))
+		return
+	}
+
+	fields, err := bindQuery(c, &filter)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+	if err = validationFailed(fields); err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	page, err := h.service.WebhookProvider.Deliveries(c.Request.Context(), filter)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// @Summary RedeliverWebhook
+// @Tags Webhook
+// @Description Queues a delivery to be sent again right away, with a fresh round of retries, whatever its status
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Param Idempotency-Key header string false "Key to retry the request safely, retries get the first response"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 400 {object} problem "Bad Request"
+// @Failure 404 {object} problem "Not Found"
+// @Failure 500 {object} problem "Internal Server Error"
+// @Router /api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) redeliverWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid webhook id: %s", c.Param("id")))
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		newErrorResponse(c, invalidInput("invalid delivery id: %s", c.Param("delivery_id")))
+		return
+	}
+
+	delivery, err := h.service.WebhookProvider.Redeliver(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		newErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Check reports whether a dependency of the service is usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks of the service.
+type Checker struct {
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// Result is safe to expose to clients, the failure details are kept in
+// Errors for logging only.
+type Result struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+	Errors map[string]error  `json:"-"`
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run executes all checks concurrently, each bounded by the checker
+// timeout. The result is ok only if every check passed.
+func (c *Checker) Run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res := Result{
+		Status: StatusOK,
+		Checks: make(map[string]string, len(c.checks)),
+		Errors: make(map[string]error),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+
+			err := nc.check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				res.Checks[nc.name] = StatusUnavailable
+				res.Errors[nc.name] = err
+				res.Status = StatusUnavailable
+				return
+			}
+			res.Checks[nc.name] = StatusOK
+		}(nc)
+	}
+	wg.Wait()
+
+	return res
+}
@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// MaxRequestIDLen bounds the ids clients send along with their requests.
+const MaxRequestIDLen = 128
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying log, so that request scoped
+// attributes such as the request id reach every layer handling it.
+func WithContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, log)
+}
+
+// FromContext returns the logger stored in ctx or fallback if there is none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if log, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// ValidRequestID accepts the ids supplied by clients, such as request ids
+// and actors, that are safe to log and echo: up to MaxRequestIDLen
+// printable ASCII characters. Both the HTTP and the gRPC API use it.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "6f1c2a9e-3d47-4b8a-9c15-2e7f0b8d4a61", want: true},
+		{id: "billing@example.com", want: true},
+		{id: strings.Repeat("a", MaxRequestIDLen), want: true},
+		{id: "", want: false},
+		{id: strings.Repeat("a", MaxRequestIDLen+1), want: false},
+		{id: "two words", want: false},
+		{id: "line\nbreak", want: false},
+		{id: "ид", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %t, want %t", tt.id, got, tt.want)
+		}
+	}
+}
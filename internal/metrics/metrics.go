@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "time_tracker"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	apiDuration  *prometheus.HistogramVec
+	apiErrors    *prometheus.CounterVec
+
+	webhookDeliveries *prometheus.CounterVec
+	webhookDuration   prometheus.Histogram
+
+	outboxEvents   *prometheus.CounterVec
+	outboxFailures *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "api_client",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of calls to the people info API.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		apiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "api_client",
+			Name:      "errors_total",
+			Help:      "Number of failed calls to the people info API.",
+		}, []string{"operation"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "webhooks",
+			Name:      "attempts_total",
+			Help:      "Number of webhook delivery attempts by outcome: delivered, retry or failed.",
+		}, []string{"result"}),
+		webhookDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "webhooks",
+			Name:      "attempt_duration_seconds",
+			Help:      "Latency of webhook delivery attempts.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		outboxEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "outbox",
+			Name:      "events_total",
+			Help:      "Number of outbox events handed to a sink by sink.",
+		}, []string{"sink"}),
+		outboxFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "outbox",
+			Name:      "failures_total",
+			Help:      "Number of failed attempts to relay outbox events to a sink by sink.",
+		}, []string{"sink"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.apiDuration,
+		m.apiErrors,
+		m.webhookDeliveries,
+		m.webhookDuration,
+		m.outboxEvents,
+		m.outboxFailures,
+	)
+
+	return m
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveAPICall(operation string, d time.Duration, err error) {
+	m.apiDuration.WithLabelValues(operation).Observe(d.Seconds())
+	if err != nil {
+		m.apiErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (m *Metrics) ObserveWebhook(result string, d time.Duration) {
+	m.webhookDeliveries.WithLabelValues(result).Inc()
+	m.webhookDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveOutbox(sink string, events int, err error) {
+	if err != nil {
+		m.outboxFailures.WithLabelValues(sink).Inc()
+		return
+	}
+	m.outboxEvents.WithLabelValues(sink).Add(float64(events))
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRunningTasks exports the number of tasks that have been started
+// but not finished yet, count is called on every scrape.
+func (m *Metrics) RegisterRunningTasks(count func() (int, error)) {
+	m.registry.MustRegister(&gaugeFunc{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tasks", "running"),
+			"Number of tasks that are currently running.",
+			nil, nil,
+		),
+		value: count,
+	})
+}
+
+// gaugeFunc is a gauge whose value is read on scrape from a source that
+// may fail, unlike prometheus.GaugeFunc the failure is reported to the scraper.
+type gaugeFunc struct {
+	desc  *prometheus.Desc
+	value func() (int, error)
+}
+
+func (g *gaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	v, err := g.value()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(g.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(v))
+}
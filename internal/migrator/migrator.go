@@ -0,0 +1,162 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	// Драйвер для выполнения миграций
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+)
+
+var (
+	ErrPending = errors.New("migrations pending")
+	ErrDirty   = errors.New("database schema is dirty")
+)
+
+// Migrator applies the schema migrations and reports whether the database
+// is up to date with them.
+type Migrator struct {
+	m   *migrate.Migrate
+	src source.Driver
+}
+
+// New creates a migrator for the migrations embedded into the binary.
+func New(cfg config.DBConfig, log *slog.Logger) (*Migrator, error) {
+	const op = "migrator.New"
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL(cfg))
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m.Log = &migrateLogger{log: log}
+
+	return &Migrator{m: m, src: src}, nil
+}
+
+func databaseURL(cfg config.DBConfig) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     cfg.Host + ":" + cfg.Port,
+		Path:     cfg.DBName,
+		RawQuery: url.Values{"sslmode": []string{cfg.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Up applies all pending migrations, migrate.ErrNoChange is returned as is.
+func (mg *Migrator) Up() error {
+	return mg.m.Up()
+}
+
+// Down rolls back the n most recently applied migrations.
+func (mg *Migrator) Down(n int) error {
+	if n < 1 {
+		return fmt.Errorf("migrator.Down: number of steps must be positive, got %d", n)
+	}
+	return mg.m.Steps(-n)
+}
+
+// Goto migrates up or down to the given version.
+func (mg *Migrator) Goto(version uint) error {
+	return mg.m.Migrate(version)
+}
+
+// Force sets the schema version without running migrations and clears the
+// dirty flag, it is used to recover from a failed migration. A version of
+// -1 marks the database as having no migrations applied.
+func (mg *Migrator) Force(version int) error {
+	return mg.m.Force(version)
+}
+
+// Version returns the currently applied version, migrate.ErrNilVersion is
+// returned if no migration has been applied yet.
+func (mg *Migrator) Version() (version uint, dirty bool, err error) {
+	return mg.m.Version()
+}
+
+// Latest returns the version of the newest available migration, zero if
+// there are none.
+func (mg *Migrator) Latest() (uint, error) {
+	const op = "migrator.Latest"
+
+	version, err := mg.src.First()
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		next, err := mg.src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		version = next
+	}
+}
+
+// Check returns ErrPending if the database is behind the latest migration
+// and ErrDirty if a previous migration failed halfway.
+func (mg *Migrator) Check() error {
+	const op = "migrator.Check"
+
+	latest, err := mg.Latest()
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		version, err = 0, nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%s: %w at version %d", op, ErrDirty, version)
+	}
+	if version < latest {
+		return fmt.Errorf("%s: %w: at version %d, latest is %d", op, ErrPending, version, latest)
+	}
+
+	return nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// migrateLogger adapts slog to the logger interface of golang-migrate.
+type migrateLogger struct {
+	log *slog.Logger
+}
+
+func (l *migrateLogger) Printf(format string, v ...any) {
+	l.log.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l *migrateLogger) Verbose() bool {
+	return false
+}
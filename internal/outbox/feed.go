@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+	"time"
+)
+
+const (
+	// gapTimeout is how long the feed waits for an event whose id was
+	// skipped: its transaction may not have committed yet. The ids of the
+	// transactions that rolled back never show up and are given up on.
+	gapTimeout = 30 * time.Second
+	// maxGap bounds the ids waited for after the sequence jumps ahead.
+	maxGap = 1000
+)
+
+// Bus is the in-process bus of the event stream.
+type Bus interface {
+	Publish(event models.Event)
+}
+
+// Tail reads the outbox in order of id, regardless of the sinks.
+type Tail interface {
+	Last(ctx context.Context) (int64, error)
+	Tail(ctx context.Context, after int64, missing []int64, limit int) ([]models.Event, error)
+}
+
+// BusFeed hands every event written to the outbox to the event stream of
+// this instance. Unlike the sinks it runs on every instance and keeps its
+// position in memory, starting with the events written after it starts.
+//
+// Ids are taken in the order the changes are made, not the order they
+// commit in, so an id skipped over may still show up. Such ids are looked
+// for again until gapTimeout has passed. The events of an entity are
+// never affected: its changes commit one after the other.
+type BusFeed struct {
+	outbox Tail
+	bus    Bus
+	cfg    config.OutboxConfig
+	log    *slog.Logger
+
+	started bool
+	last    int64
+	missing map[int64]time.Time
+}
+
+func NewBusFeed(s *storage.Storage, bus Bus, cfg config.OutboxConfig, log *slog.Logger) *BusFeed {
+	return &BusFeed{
+		outbox:  s.OutboxProvider,
+		bus:     bus,
+		cfg:     cfg,
+		log:     log,
+		missing: make(map[int64]time.Time),
+	}
+}
+
+// Run feeds the bus right away and then on every poll interval until ctx
+// is done.
+func (f *BusFeed) Run(ctx context.Context) {
+	const op = "outbox.BusFeed.Run"
+
+	log := f.log.With(slog.String("op", op))
+
+	for {
+		if err := f.poll(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Error("failed to feed outbox events to the bus", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.cfg.PollInterval):
+		}
+	}
+}
+
+// poll publishes the events written since the last poll, batch after
+// batch, along with the skipped ones that have shown up since.
+func (f *BusFeed) poll(ctx context.Context, now time.Time) error {
+	if !f.started {
+		last, err := f.outbox.Last(ctx)
+		if err != nil {
+			return err
+		}
+		f.last, f.started = last, true
+	}
+
+	for ctx.Err() == nil {
+		missing := make([]int64, 0, len(f.missing))
+		for id := range f.missing {
+			missing = append(missing, id)
+		}
+
+		events, err := f.outbox.Tail(ctx, f.last, missing, f.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			f.bus.Publish(e)
+			f.seen(int64(e.ID), now)
+		}
+
+		for id, since := range f.missing {
+			if now.Sub(since) >= gapTimeout {
+				delete(f.missing, id)
+			}
+		}
+
+		if len(events) < f.cfg.BatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// seen moves the position past the id, the ids it skips are waited for.
+func (f *BusFeed) seen(id int64, now time.Time) {
+	if id <= f.last {
+		delete(f.missing, id)
+		return
+	}
+	if id-f.last-1 <= maxGap {
+		for skipped := f.last + 1; skipped < id; skipped++ {
+			f.missing[skipped] = now
+		}
+	}
+	f.last = id
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memTail serves the committed events, in order of id.
+type memTail struct {
+	committed []models.Event
+}
+
+func (t *memTail) Last(context.Context) (int64, error) {
+	if len(t.committed) == 0 {
+		return 0, nil
+	}
+	return int64(t.committed[len(t.committed)-1].ID), nil
+}
+
+func (t *memTail) Tail(_ context.Context, after int64, missing []int64, limit int) ([]models.Event, error) {
+	var out []models.Event
+	for _, e := range t.committed {
+		if len(out) < limit && (int64(e.ID) > after || slices.Contains(missing, int64(e.ID))) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (t *memTail) commit(e models.Event) {
+	t.committed = append(t.committed, e)
+	slices.SortFunc(t.committed, func(a, b models.Event) int { return int(a.ID) - int(b.ID) })
+}
+
+type memBus struct {
+	published []uint64
+}
+
+func (b *memBus) Publish(e models.Event) {
+	b.published = append(b.published, e.ID)
+}
+
+func newTestFeed(tail Tail, bus Bus) *BusFeed {
+	return &BusFeed{
+		outbox:  tail,
+		bus:     bus,
+		cfg:     config.OutboxConfig{BatchSize: 2},
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		missing: make(map[int64]time.Time),
+	}
+}
+
+func TestBusFeedStartsAfterExistingEvents(t *testing.T) {
+	ctx := context.Background()
+	tail := &memTail{}
+	tail.commit(taskEvent(1, 1))
+	tail.commit(taskEvent(2, 1))
+	bus := &memBus{}
+	f := newTestFeed(tail, bus)
+
+	if err := f.poll(ctx, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	tail.commit(taskEvent(3, 1))
+	tail.commit(taskEvent(4, 2))
+	tail.commit(taskEvent(5, 1))
+	if err := f.poll(ctx, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if !slices.Equal(bus.published, []uint64{3, 4, 5}) {
+		t.Fatalf("published %v, want [3 4 5]", bus.published)
+	}
+}
+
+func TestBusFeedPicksUpLateCommits(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now()
+	tail := &memTail{}
+	bus := &memBus{}
+	f := newTestFeed(tail, bus)
+
+	if err := f.poll(ctx, start); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	// 2 and 3 are taken by transactions that have not committed yet
+	tail.commit(taskEvent(1, 1))
+	tail.commit(taskEvent(4, 2))
+	if err := f.poll(ctx, start); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	tail.commit(taskEvent(2, 3))
+	if err := f.poll(ctx, start.Add(time.Second)); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if !slices.Equal(bus.published, []uint64{1, 4, 2}) {
+		t.Fatalf("published %v, want [1 4 2]", bus.published)
+	}
+
+	// 3 rolled back, it is given up on after the timeout
+	if err := f.poll(ctx, start.Add(gapTimeout)); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(f.missing) != 0 {
+		t.Fatalf("still waiting for %v", f.missing)
+	}
+
+	tail.commit(taskEvent(3, 4))
+	if err := f.poll(ctx, start.Add(gapTimeout)); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if !slices.Equal(bus.published, []uint64{1, 4, 2}) {
+		t.Fatalf("published %v after the gap was given up on", bus.published)
+	}
+}
+
+func TestBusFeedBoundsGaps(t *testing.T) {
+	f := newTestFeed(&memTail{}, &memBus{})
+	f.started = true
+
+	f.seen(maxGap+10, time.Now())
+
+	if len(f.missing) != 0 || f.last != maxGap+10 {
+		t.Fatalf("after a jump: last %d, %d ids missing", f.last, len(f.missing))
+	}
+}
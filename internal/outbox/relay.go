@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink receives the events relayed from the outbox. The events come in
+// order of id and a batch is followed by the next one only once Publish
+// has succeeded, a failed batch is handed over again. A sink may thus see
+// an event more than once and should skip the ids it has already seen.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, events []models.Event) error
+}
+
+// TxSink is a sink that only writes to the database. It publishes in the
+// transaction that records the events as relayed, so it gets every event
+// exactly once.
+type TxSink interface {
+	Sink
+	Transactional()
+}
+
+// Outbox hands out the events a sink has not accepted yet and records the
+// ones it has.
+type Outbox interface {
+	Claim(ctx context.Context, sink, owner string, limit int, lease time.Duration) ([]models.Event, error)
+	Published(ctx context.Context, sink, owner string, ids []int64, sinks []string) error
+	Settle(ctx context.Context, sinks []string) (int64, error)
+}
+
+// Relay hands the events written to the outbox to the sinks, at least once
+// and in the order of the changes of every user and task. Every instance
+// runs one, a sink is relayed to by the instance holding its lease. The
+// database is only used to claim the events and to record them as relayed,
+// never while a sink is publishing them.
+type Relay struct {
+	outbox  Outbox
+	tx      storage.Transactor
+	sinks   []Sink
+	names   []string
+	owner   string
+	cfg     config.OutboxConfig
+	metrics *metrics.Metrics
+	log     *slog.Logger
+}
+
+func NewRelay(s *storage.Storage, cfg config.OutboxConfig, sinks []Sink, metrics *metrics.Metrics, log *slog.Logger) *Relay {
+	names := make([]string, 0, len(sinks))
+	for _, sink := range sinks {
+		names = append(names, sink.Name())
+	}
+
+	return &Relay{
+		outbox:  s.OutboxProvider,
+		tx:      s.Transactor,
+		sinks:   sinks,
+		names:   names,
+		owner:   owner(),
+		cfg:     cfg,
+		metrics: metrics,
+		log:     log,
+	}
+}
+
+// owner identifies this instance as the holder of sink leases.
+func owner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Run relays the events to every sink on its own until ctx is done, so that
+// a slow or failing sink holds back only itself.
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.runSettle(ctx)
+	}()
+
+	for _, sink := range r.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runSink(ctx, sink)
+		}()
+	}
+	wg.Wait()
+}
+
+// runSettle marks as published the events accepted by all the configured
+// sinks that no sink records as such: the ones that were waiting only for
+// a sink removed from the configuration, once at the start, and when no
+// sink is configured, all of them on every poll interval.
+func (r *Relay) runSettle(ctx context.Context) {
+	const op = "outbox.Relay.runSettle"
+
+	log := r.log.With(slog.String("op", op))
+
+	for {
+		n, err := r.outbox.Settle(ctx, r.names)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to settle outbox events", slog.String("error", err.Error()))
+		}
+		if n > 0 {
+			log.Debug("settled outbox events", slog.Int64("events", n))
+		}
+		if err == nil && len(r.sinks) > 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// runSink relays the events to the sink right away and then on every poll
+// interval, after a failure it waits for the backoff instead.
+func (r *Relay) runSink(ctx context.Context, sink Sink) {
+	const op = "outbox.Relay.runSink"
+
+	log := r.log.With(slog.String("op", op), slog.String("sink", sink.Name()))
+
+	failures := 0
+	for {
+		wait := r.cfg.PollInterval
+		if err := r.drain(ctx, sink); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			wait = backoff(r.cfg, failures)
+			log.Error("failed to relay outbox events",
+				slog.String("error", err.Error()), slog.Int("failures", failures), slog.Duration("retry_in", wait))
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// drain relays batch after batch, until fewer than a full batch are left.
+func (r *Relay) drain(ctx context.Context, sink Sink) error {
+	for ctx.Err() == nil {
+		n, err := r.relay(ctx, sink)
+		if err != nil {
+			return err
+		}
+		if n < r.cfg.BatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// relay claims a batch of events for the sink, hands it over and records
+// that the sink has accepted it. Nothing is claimed while another instance
+// holds the lease of the sink.
+func (r *Relay) relay(ctx context.Context, sink Sink) (int, error) {
+	const op = "outbox.Relay.relay"
+
+	events, err := r.outbox.Claim(ctx, sink.Name(), r.owner, r.cfg.BatchSize, r.cfg.Lease)
+	if err == nil && len(events) > 0 {
+		err = r.publish(ctx, sink, events)
+	}
+	if ctx.Err() == nil {
+		r.metrics.ObserveOutbox(sink.Name(), len(events), err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(events), nil
+}
+
+func (r *Relay) publish(ctx context.Context, sink Sink, events []models.Event) error {
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, int64(e.ID))
+	}
+
+	if _, ok := sink.(TxSink); ok {
+		return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if err := sink.Publish(ctx, events); err != nil {
+				return err
+			}
+			return r.outbox.Published(ctx, sink.Name(), r.owner, ids, r.names)
+		})
+	}
+
+	if err := sink.Publish(ctx, events); err != nil {
+		return err
+	}
+	return r.outbox.Published(ctx, sink.Name(), r.owner, ids, r.names)
+}
+
+// backoff returns the wait after the given number of failures in a row,
+// doubled after every failure and capped.
+func backoff(cfg config.OutboxConfig, failures int) time.Duration {
+	wait := cfg.Backoff
+	for i := 1; i < failures && wait < cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, cfg.MaxBackoff)
+}
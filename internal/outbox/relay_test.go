@@ -0,0 +1,276 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	cfg := config.OutboxConfig{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 3, want: 4 * time.Second},
+		{failures: 4, want: 5 * time.Second},
+		{failures: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(cfg, tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// memOutbox keeps the outbox in memory with the semantics of the storage:
+// a sink gets the events it has not accepted in order of id, while its
+// lease is held by a single owner.
+type memOutbox struct {
+	events    []models.Event
+	published map[uint64][]string
+	done      map[uint64]bool
+	leases    map[string]string
+}
+
+func newMemOutbox(events ...models.Event) *memOutbox {
+	return &memOutbox{
+		events:    events,
+		published: make(map[uint64][]string),
+		done:      make(map[uint64]bool),
+		leases:    make(map[string]string),
+	}
+}
+
+func (o *memOutbox) Claim(_ context.Context, sink, owner string, limit int, _ time.Duration) ([]models.Event, error) {
+	if holder, ok := o.leases[sink]; ok && holder != owner {
+		return nil, nil
+	}
+	o.leases[sink] = owner
+
+	var claimed []models.Event
+	for _, e := range o.events {
+		if len(claimed) == limit {
+			break
+		}
+		if !o.done[e.ID] && !slices.Contains(o.published[e.ID], sink) {
+			claimed = append(claimed, e)
+		}
+	}
+	return claimed, nil
+}
+
+func (o *memOutbox) Published(_ context.Context, sink, owner string, ids []int64, sinks []string) error {
+	if o.leases[sink] != owner {
+		return storage.ErrLeaseLost
+	}
+	for _, id := range ids {
+		o.published[uint64(id)] = append(o.published[uint64(id)], sink)
+	}
+	_, err := o.Settle(context.Background(), sinks)
+	return err
+}
+
+func (o *memOutbox) Settle(_ context.Context, sinks []string) (int64, error) {
+	var n int64
+	for _, e := range o.events {
+		if o.done[e.ID] {
+			continue
+		}
+		all := true
+		for _, s := range sinks {
+			all = all && slices.Contains(o.published[e.ID], s)
+		}
+		if all {
+			o.done[e.ID] = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// recordingSink keeps what it was handed and fails the publishes listed
+// in fail, counted from 1.
+type recordingSink struct {
+	name     string
+	fail     []int
+	calls    int
+	received []models.Event
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(_ context.Context, events []models.Event) error {
+	s.calls++
+	if slices.Contains(s.fail, s.calls) {
+		return errors.New("sink unavailable")
+	}
+	s.received = append(s.received, events...)
+	return nil
+}
+
+func taskEvent(id uint64, taskID int) models.Event {
+	return models.Event{ID: id, Type: models.EventTaskStopped, Task: &models.Task{Id: taskID}}
+}
+
+func newTestRelay(o Outbox, batch int, sinks ...Sink) *Relay {
+	names := make([]string, 0, len(sinks))
+	for _, s := range sinks {
+		names = append(names, s.Name())
+	}
+	return &Relay{
+		outbox:  o,
+		tx:      noTx{},
+		sinks:   sinks,
+		names:   names,
+		owner:   "test",
+		cfg:     config.OutboxConfig{BatchSize: batch},
+		metrics: metrics.New(),
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func ids(events []models.Event) []uint64 {
+	out := make([]uint64, 0, len(events))
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestRelayRedeliversFailedBatchInOrder(t *testing.T) {
+	ctx := context.Background()
+	o := newMemOutbox(taskEvent(1, 10), taskEvent(2, 20), taskEvent(3, 10), taskEvent(4, 10), taskEvent(5, 20))
+	flaky := &recordingSink{name: "http", fail: []int{2}}
+	steady := &recordingSink{name: "file"}
+	r := newTestRelay(o, 2, flaky, steady)
+
+	// the second batch fails, the drain stops there
+	if err := r.drain(ctx, flaky); err == nil {
+		t.Fatal("drain: expected the failure of the sink")
+	}
+	if got := ids(flaky.received); !slices.Equal(got, []uint64{1, 2}) {
+		t.Fatalf("received before the failure %v, want [1 2]", got)
+	}
+
+	// the other sink is not held back
+	if err := r.drain(ctx, steady); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if got := ids(steady.received); !slices.Equal(got, []uint64{1, 2, 3, 4, 5}) {
+		t.Fatalf("steady sink received %v", got)
+	}
+	if o.done[3] {
+		t.Fatal("event 3 is published before the failed sink accepted it")
+	}
+
+	// the retry starts with the failed batch
+	if err := r.drain(ctx, flaky); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if got := ids(flaky.received); !slices.Equal(got, []uint64{1, 2, 3, 4, 5}) {
+		t.Fatalf("received after the retry %v, want every event once in order", got)
+	}
+	for _, e := range o.events {
+		if !o.done[e.ID] {
+			t.Errorf("event %d is not published", e.ID)
+		}
+	}
+}
+
+func TestRelayKeepsOrderPerAggregate(t *testing.T) {
+	ctx := context.Background()
+	var events []models.Event
+	for i := 1; i <= 30; i++ {
+		events = append(events, taskEvent(uint64(i), i%4))
+	}
+	sink := &recordingSink{name: "http", fail: []int{1, 3, 4, 7}}
+	r := newTestRelay(newMemOutbox(events...), 4, sink)
+
+	for attempt := 0; attempt < 20; attempt++ {
+		if err := r.drain(ctx, sink); err == nil {
+			break
+		}
+	}
+
+	last := make(map[int]uint64)
+	for _, e := range sink.received {
+		if e.ID <= last[e.Task.Id] {
+			t.Fatalf("event %d of task %d received after event %d", e.ID, e.Task.Id, last[e.Task.Id])
+		}
+		last[e.Task.Id] = e.ID
+	}
+	if len(sink.received) != len(events) {
+		t.Fatalf("received %d events, want %d", len(sink.received), len(events))
+	}
+}
+
+func TestRelaySkipsSinkLeasedElsewhere(t *testing.T) {
+	o := newMemOutbox(taskEvent(1, 1))
+	o.leases["http"] = "other instance"
+	sink := &recordingSink{name: "http"}
+	r := newTestRelay(o, 10, sink)
+
+	n, err := r.relay(context.Background(), sink)
+	if err != nil || n != 0 || sink.calls != 0 {
+		t.Fatalf("relay = %d, %v with %d publishes, want nothing done", n, err, sink.calls)
+	}
+}
+
+// leaseStealer takes the lease of the sink over while it publishes.
+type leaseStealer struct {
+	recordingSink
+	o *memOutbox
+}
+
+func (s *leaseStealer) Publish(ctx context.Context, events []models.Event) error {
+	s.o.leases[s.name] = "other instance"
+	return s.recordingSink.Publish(ctx, events)
+}
+
+func TestRelayLeaseLostLeavesEventsPending(t *testing.T) {
+	o := newMemOutbox(taskEvent(1, 1))
+	sink := &leaseStealer{recordingSink: recordingSink{name: "http"}, o: o}
+	r := newTestRelay(o, 10, sink)
+
+	if _, err := r.relay(context.Background(), sink); !errors.Is(err, storage.ErrLeaseLost) {
+		t.Fatalf("relay error = %v, want ErrLeaseLost", err)
+	}
+	if len(o.published[1]) != 0 {
+		t.Fatal("event recorded as accepted without the lease")
+	}
+}
+
+func TestRelaySettlesEventsOfRemovedSinks(t *testing.T) {
+	o := newMemOutbox(taskEvent(1, 1), taskEvent(2, 1))
+	// accepted by file, still waiting for http, which is no longer configured
+	o.published[1] = []string{"file"}
+	r := newTestRelay(o, 10, &recordingSink{name: "file"})
+
+	r.runSettle(context.Background())
+
+	if !o.done[1] {
+		t.Error("event accepted by every configured sink is not published")
+	}
+	if o.done[2] {
+		t.Error("event not accepted by file is published")
+	}
+}
@@ -0,0 +1,165 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// NewSinks returns the sinks named in the configuration, in its order. The
+// bus is not one of them, every instance feeds its own with a BusFeed.
+func NewSinks(cfg config.OutboxConfig, s *storage.Storage) []Sink {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for _, name := range cfg.Sinks {
+		switch name {
+		case config.SinkWebhooks:
+			sinks = append(sinks, NewWebhookSink(s.WebhookProvider))
+		case config.SinkFile:
+			sinks = append(sinks, NewFileSink(cfg.FilePath))
+		case config.SinkHTTP:
+			sinks = append(sinks, NewHTTPSink(cfg.HTTPURL, cfg.HTTPTimeout))
+		}
+	}
+	return sinks
+}
+
+// WebhookSink queues the events for the webhooks subscribed to them. The
+// deliveries are added in the transaction that records the events as
+// relayed, so every event is queued exactly once.
+type WebhookSink struct {
+	webhooks storage.WebhookProvider
+}
+
+func NewWebhookSink(webhooks storage.WebhookProvider) *WebhookSink {
+	return &WebhookSink{webhooks: webhooks}
+}
+
+func (s *WebhookSink) Name() string {
+	return config.SinkWebhooks
+}
+
+// Transactional marks the sink as a TxSink.
+func (s *WebhookSink) Transactional() {}
+
+func (s *WebhookSink) Publish(ctx context.Context, events []models.Event) error {
+	for _, e := range events {
+		if err := s.webhooks.Enqueue(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileSink appends the events to a file, one JSON object per line.
+type FileSink struct {
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return config.SinkFile
+}
+
+// Publish writes the batch and flushes it to disk. The file is opened for
+// every batch, so that it can be rotated by moving it away.
+func (s *FileSink) Publish(_ context.Context, events []models.Event) error {
+	const op = "outbox.FileSink.Publish"
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		// the batch is written again on the retry, a partly written one
+		// would leave a broken line behind
+		_ = f.Truncate(info.Size())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f.Close()
+}
+
+// HTTPSink POSTs every batch of events to a URL as a JSON array, any
+// response other than 2xx has the batch sent again.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{
+		url: url,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   timeout,
+			// a redirected POST would turn into a GET, it counts as a failure
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (s *HTTPSink) Name() string {
+	return config.SinkHTTP
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, events []models.Event) error {
+	const op = "outbox.HTTPSink.Publish"
+
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "time-tracker-outbox")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: unexpected status %d", op, resp.StatusCode)
+	}
+
+	return nil
+}
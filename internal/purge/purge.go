@@ -0,0 +1,110 @@
+package purge
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// Purger permanently removes the records deleted before the given time.
+type Purger interface {
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Worker periodically removes users and tasks whose retention period has
+// passed since they were deleted, along with expired idempotency keys and
+// the webhook deliveries finished and outbox events published before the
+// retention period.
+type Worker struct {
+	tasks      Purger
+	users      Purger
+	keys       Purger
+	deliveries Purger
+	outbox     Purger
+	retention  time.Duration
+	keyTTL     time.Duration
+	interval   time.Duration
+	log        *slog.Logger
+}
+
+func NewWorker(s *storage.Storage, cfg config.PurgeConfig, idempotency config.IdempotencyConfig, log *slog.Logger) *Worker {
+	return &Worker{
+		tasks:      s.TaskProvider,
+		users:      s.UserProvider,
+		keys:       s.IdempotencyProvider,
+		deliveries: s.WebhookProvider,
+		outbox:     s.OutboxProvider,
+		retention:  cfg.Retention,
+		keyTTL:     idempotency.TTL,
+		interval:   cfg.Interval,
+		log:        log,
+	}
+}
+
+// Run purges once right away and then on every interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) purge(ctx context.Context) {
+	const op = "purge.Worker.purge"
+
+	log := w.log.With(slog.String("op", op))
+	before := time.Now().Add(-w.retention)
+
+	tasks, err := w.tasks.Purge(ctx, before)
+	if err != nil {
+		log.Error("failed to purge tasks", slog.String("error", err.Error()))
+		return
+	}
+
+	users, err := w.users.Purge(ctx, before)
+	if err != nil {
+		log.Error("failed to purge users", slog.String("error", err.Error()))
+		return
+	}
+
+	if tasks > 0 || users > 0 {
+		log.Info("purged deleted records", slog.Int64("tasks", tasks), slog.Int64("users", users))
+	}
+
+	keys, err := w.keys.Purge(ctx, time.Now().Add(-w.keyTTL))
+	if err != nil {
+		log.Error("failed to purge idempotency keys", slog.String("error", err.Error()))
+		return
+	}
+	if keys > 0 {
+		log.Debug("purged expired idempotency keys", slog.Int64("keys", keys))
+	}
+
+	deliveries, err := w.deliveries.Purge(ctx, before)
+	if err != nil {
+		log.Error("failed to purge webhook deliveries", slog.String("error", err.Error()))
+		return
+	}
+	if deliveries > 0 {
+		log.Debug("purged finished webhook deliveries", slog.Int64("deliveries", deliveries))
+	}
+
+	events, err := w.outbox.Purge(ctx, before)
+	if err != nil {
+		log.Error("failed to purge outbox events", slog.String("error", err.Error()))
+		return
+	}
+	if events > 0 {
+		log.Debug("purged published outbox events", slog.Int64("events", events))
+	}
+}
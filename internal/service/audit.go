@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/audit"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+)
+
+type AuditService struct {
+	storage storage.AuditProvider
+	log     *slog.Logger
+}
+
+func NewAuditService(s storage.AuditProvider, log *slog.Logger) *AuditService {
+	return &AuditService{
+		storage: s,
+		log:     log,
+	}
+}
+
+func (as *AuditService) Events(ctx context.Context, filter models.AuditFilter) (page models.AuditPage, err error) {
+	const op = "service.audit.Events"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, as.log).With(slog.String("op", op))
+
+	log.Debug("received request to get audit events", slog.Any("filter", filter))
+
+	page, err = as.storage.Events(ctx, filter)
+	if err != nil {
+		log.Warn("failed getting audit events", slog.String("error", err.Error()))
+		return page, err
+	}
+
+	log.Debug("successfully retrieved audit events", slog.Int("count", len(page.Events)))
+	return page, nil
+}
+
+// recordChange appends an audit event for a change of an entity, before and
+// after are its states around the change, nil when it did not exist.
+func recordChange(ctx context.Context, events storage.AuditProvider, action, entity string, id int, before, after any) error {
+	b, a, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	origin := audit.OriginFrom(ctx)
+	event := models.AuditEvent{
+		Actor:    origin.Actor,
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+		Before:   b,
+		After:    a,
+	}
+	if origin.RequestID != "" {
+		event.RequestID = &origin.RequestID
+	}
+
+	return events.Record(ctx, event)
+}
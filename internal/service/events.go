@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"log/slog"
+	"time"
+)
+
+// EventBus delivers the events about changes of users and tasks, relayed
+// to it from the outbox, to the subscribers of the event stream.
+type EventBus interface {
+	Subscribe(ctx context.Context, filter models.EventFilter) <-chan models.Event
+}
+
+type EventService struct {
+	bus EventBus
+	log *slog.Logger
+}
+
+func NewEventService(bus EventBus, log *slog.Logger) *EventService {
+	return &EventService{
+		bus: bus,
+		log: log,
+	}
+}
+
+// Subscribe streams the events matching the filter until ctx is done, the
+// channel is closed early if the subscriber cannot keep up.
+func (es *EventService) Subscribe(ctx context.Context, filter models.EventFilter) <-chan models.Event {
+	const op = "service.event.Subscribe"
+
+	log := logger.FromContext(ctx, es.log).With(slog.String("op", op))
+
+	log.Debug("received request to subscribe to events", slog.Any("filter", filter))
+
+	return es.bus.Subscribe(ctx, filter)
+}
+
+func taskEvent(typ string, task models.Task) models.Event {
+	return models.Event{Type: typ, OccurredAt: time.Now().UTC(), UserID: task.UserID, Task: &task}
+}
+
+func userEvent(typ string, user models.User) models.Event {
+	return models.Event{Type: typ, OccurredAt: time.Now().UTC(), UserID: user.ID, User: &models.EventUser{
+		ID:        user.ID,
+		Version:   user.Version,
+		DeletedAt: user.DeletedAt,
+		ErasedAt:  user.ErasedAt,
+	}}
+}
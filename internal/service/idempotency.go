@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+	"time"
+)
+
+var (
+	// ErrIdempotencyKeyReused means the key was used before for a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused for a different request")
+	// ErrRequestInProgress means a request with the same key has not completed yet.
+	ErrRequestInProgress = errors.New("request with the same idempotency key is in progress")
+)
+
+type IdempotencyService struct {
+	storage     storage.IdempotencyProvider
+	ttl         time.Duration
+	lockTimeout time.Duration
+	log         *slog.Logger
+}
+
+func NewIdempotencyService(s storage.IdempotencyProvider, cfg config.IdempotencyConfig, log *slog.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		storage:     s,
+		ttl:         cfg.TTL,
+		lockTimeout: cfg.LockTimeout,
+		log:         log,
+	}
+}
+
+// Begin claims the key for the request. When the request already completed
+// the stored key is returned with its response to replay, otherwise the
+// returned key has no status and the request is to be served.
+func (is *IdempotencyService) Begin(ctx context.Context, key models.IdempotencyKey) (stored models.IdempotencyKey, err error) {
+	const op = "service.idempotency.Begin"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, is.log).With(slog.String("op", op), slog.String("key", key.Key))
+
+	now := time.Now()
+	stored, reserved, err := is.storage.Reserve(ctx, key, now.Add(-is.ttl), now.Add(-is.lockTimeout))
+	if err != nil {
+		log.Error("failed to reserve idempotency key", slog.String("error", err.Error()))
+		return stored, err
+	}
+
+	switch {
+	case reserved:
+		log.Debug("reserved idempotency key")
+	case stored.RequestHash != key.RequestHash:
+		log.Warn("idempotency key reused for a different request")
+		return stored, ErrIdempotencyKeyReused
+	case stored.StatusCode == 0:
+		log.Warn("request with the idempotency key is in progress")
+		return stored, ErrRequestInProgress
+	default:
+		log.Debug("replaying stored response", slog.Int("status", stored.StatusCode))
+	}
+
+	return stored, nil
+}
+
+// Complete stores the response of the request, which is replayed to its retries.
+func (is *IdempotencyService) Complete(ctx context.Context, key models.IdempotencyKey) (err error) {
+	const op = "service.idempotency.Complete"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if err = is.storage.Complete(ctx, key); err != nil {
+		logger.FromContext(ctx, is.log).Error("failed to store response", slog.String("op", op), slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+// Release frees the key of a request that failed, so that it can be retried.
+func (is *IdempotencyService) Release(ctx context.Context, key models.IdempotencyKey) (err error) {
+	const op = "service.idempotency.Release"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	if err = is.storage.Release(ctx, key); err != nil {
+		logger.FromContext(ctx, is.log).Error("failed to release idempotency key", slog.String("op", op), slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// personalFields are the user fields that identify a person, named as in
+// the JSON representation kept by the audit log.
+var personalFields = []string{"passport_number", "name", "surname", "patronymic", "addr"}
+
+// PrivacyService serves the requests of users regarding their personal data.
+type PrivacyService struct {
+	users  storage.UserProvider
+	tasks  storage.TaskProvider
+	events storage.AuditProvider
+	tx     storage.Transactor
+	outbox storage.OutboxProvider
+	keys   storage.IdempotencyProvider
+	log    *slog.Logger
+}
+
+func NewPrivacyService(s *storage.Storage, log *slog.Logger) *PrivacyService {
+	return &PrivacyService{
+		users:  s.UserProvider,
+		tasks:  s.TaskProvider,
+		events: s.AuditProvider,
+		tx:     s.Transactor,
+		outbox: s.OutboxProvider,
+		keys:   s.IdempotencyProvider,
+		log:    log,
+	}
+}
+
+func (ps *PrivacyService) Export(ctx context.Context, id int) (export models.UserExport, err error) {
+	const op = "service.privacy.Export"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ps.log).With(slog.String("op", op))
+
+	log.Debug("received request to export user data", slog.Int("id", id))
+
+	err = ps.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := ps.users.UserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		tasks, err := ps.tasks.TasksByUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		export = models.UserExport{
+			ExportedAt: time.Now(),
+			User:       user,
+			Tasks:      tasks,
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn(err.Error())
+			return export, err
+		}
+		log.Error("failed exporting user data", slog.String("error", err.Error()))
+		return export, err
+	}
+
+	log.Info("user data exported", slog.Int("tasks", len(export.Tasks)))
+	return export, nil
+}
+
+// Erase anonymizes the user and removes their personal data from the audit
+// log and the stored responses to their requests. Events never carry it.
+// Their tasks are kept, so that reports on tracked time stay intact.
+func (ps *PrivacyService) Erase(ctx context.Context, id int) (err error) {
+	const op = "service.privacy.Erase"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ps.log).With(slog.String("op", op))
+
+	log.Debug("received request to erase user data", slog.Int("id", id))
+
+	err = ps.tx.WithinTx(ctx, func(ctx context.Context) error {
+		erased, err := ps.users.Erase(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = ps.events.Redact(ctx, models.AuditEntityUser, id, personalFields); err != nil {
+			return err
+		}
+		if err = ps.keys.PurgeUser(ctx, id); err != nil {
+			return err
+		}
+		if err := ps.outbox.Add(ctx, userEvent(models.EventUserUpdated, erased)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ps.events, models.AuditActionErase, models.AuditEntityUser, id, nil, erased)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn(err.Error())
+			return err
+		}
+		log.Error("failed erasing user data", slog.String("error", err.Error()))
+		return err
+	}
+
+	log.Info("user data erased")
+	return nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The mem types keep in memory what the erasure of a user reaches: the
+// user, the audit log, the outbox and the stored responses.
+type memUser struct {
+	storage.UserProvider
+	user models.User
+}
+
+type memAudit struct {
+	storage.AuditProvider
+	audit []models.AuditEvent
+}
+
+type memOutbox struct {
+	storage.OutboxProvider
+	outbox []models.Event
+}
+
+type memKeys struct {
+	storage.IdempotencyProvider
+	keys []models.IdempotencyKey
+}
+
+func (m *memUser) Erase(_ context.Context, id int) (models.User, error) {
+	now := time.Now()
+	m.user = models.User{ID: id, Version: m.user.Version + 1, ErasedAt: &now}
+	return m.user, nil
+}
+
+func (m *memAudit) Record(_ context.Context, event models.AuditEvent) error {
+	m.audit = append(m.audit, event)
+	return nil
+}
+
+func (m *memAudit) Redact(_ context.Context, entity string, entityID int, fields []string) error {
+	for i, e := range m.audit {
+		if e.Entity != entity || e.EntityID != entityID {
+			continue
+		}
+		for _, state := range []*json.RawMessage{&m.audit[i].Before, &m.audit[i].After} {
+			if *state == nil {
+				continue
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(*state, &doc); err != nil {
+				return err
+			}
+			for _, f := range fields {
+				delete(doc, f)
+			}
+			*state, _ = json.Marshal(doc)
+		}
+	}
+	return nil
+}
+
+func (m *memOutbox) Add(_ context.Context, event models.Event) error {
+	m.outbox = append(m.outbox, event)
+	return nil
+}
+
+func (m *memKeys) PurgeUser(_ context.Context, userID int) error {
+	m.keys = slices.DeleteFunc(m.keys, func(k models.IdempotencyKey) bool { return k.UserID == userID })
+	return nil
+}
+
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestEraseLeavesNoPersonalData(t *testing.T) {
+	ctx := context.Background()
+	user := models.User{ID: 7, PassportNumber: "1234 567890", Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich", Address: "Moscow, Lenina 1", Version: 1}
+	personal := []string{user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address}
+
+	users, audit, outbox := &memUser{user: user}, &memAudit{}, &memOutbox{}
+	body, _ := json.Marshal(user)
+	keys := &memKeys{keys: []models.IdempotencyKey{
+		{Key: "patch", StatusCode: 200, Body: body, UserID: user.ID},
+		{Key: "other user", StatusCode: 200, Body: []byte(`{"id":8}`), UserID: 8},
+	}}
+	if err := recordChange(ctx, audit, models.AuditActionCreate, models.AuditEntityUser, user.ID, nil, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Add(ctx, userEvent(models.EventUserCreated, user)); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := &PrivacyService{
+		users:  users,
+		events: audit,
+		tx:     noTx{},
+		outbox: outbox,
+		keys:   keys,
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := ps.Erase(ctx, user.ID); err != nil {
+		t.Fatalf("Erase: %v", err)
+	}
+
+	left, err := json.Marshal(struct {
+		User   models.User
+		Audit  []models.AuditEvent
+		Outbox []models.Event
+		Keys   []models.IdempotencyKey
+	}{users.user, audit.audit, outbox.outbox, keys.keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range personal {
+		if strings.Contains(string(left), p) {
+			t.Errorf("%q is left after the erasure: %s", p, left)
+		}
+	}
+
+	if len(keys.keys) != 1 || keys.keys[0].UserID != 8 {
+		t.Errorf("stored responses %+v, want only the one of the other user", keys.keys)
+	}
+	if len(outbox.outbox) != 2 || outbox.outbox[1].Type != models.EventUserUpdated || outbox.outbox[1].User.ErasedAt == nil {
+		t.Errorf("outbox %+v, want the erasure announced", outbox.outbox)
+	}
+}
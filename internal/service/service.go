@@ -1,34 +1,109 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/config"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
 	"github.com/3XBAT/time-tracker/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
 )
 
+var ErrInvalidPassport = errors.New("invalid passport number")
+
+var tracer = otel.Tracer("github.com/3XBAT/time-tracker/internal/service")
+
 type UserProvider interface {
-	Users(params models.QueryParams) ([]models.User, error) //параметры нужны для фильтрации, если они пусты, то просто выводим все записи
-	Create(passportNumber string) (int, error)
-	Update(user models.UpdateUserInput, id int) error
-	Delete(id int) error
-	UserById(id int) (models.User, error)
+	Users(ctx context.Context, params models.QueryParams) (models.UserPage, error) //параметры нужны для фильтрации, если они пусты, то просто выводим все записи
+	Create(ctx context.Context, passportNumber string) (int, error)
+	Update(ctx context.Context, user models.UpdateUserInput, id int) (models.User, error)
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) error
+	UserById(ctx context.Context, id int) (models.User, error)
+	UsersByIDs(ctx context.Context, ids []int) ([]models.User, error)
+}
+
+// PeopleInfoProvider looks up personal data of a passport holder.
+type PeopleInfoProvider interface {
+	UserInfo(ctx context.Context, passportNumber, passportSeries string) (*models.User, error)
 }
 
 type TaskProvider interface {
-	Create(input models.InputTaskCreate) (int, error)
-	Update(task models.InputTaskUpdate) error
-	Delete(taskDeleteRequest models.InputTaskDelete) error
-	Tasks(task models.InputTask) ([]models.OutputTask, error)
+	Create(ctx context.Context, input models.InputTaskCreate) (int, error)
+	Update(ctx context.Context, task models.InputTaskUpdate) (models.Task, error)
+	Patch(ctx context.Context, taskID int, patch models.InputTaskPatch) (models.Task, error)
+	Delete(ctx context.Context, taskDeleteRequest models.InputTaskDelete) error
+	Tasks(ctx context.Context, task models.InputTask) (models.TaskPage, error)
+	Restore(ctx context.Context, taskID int) error
+	TaskById(ctx context.Context, taskID int) (models.Task, error)
+	Batch(ctx context.Context, ops []models.TaskOperation, atomic bool) ([]models.TaskOperationResult, error)
+	TasksByUsers(ctx context.Context, userIDs []int, period models.TaskPeriod) ([]models.Task, error)
+}
+
+// AuditProvider reads the log of changes to users and tasks.
+type AuditProvider interface {
+	Events(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error)
+}
+
+// PrivacyProvider exports and erases the personal data of a user.
+type PrivacyProvider interface {
+	Export(ctx context.Context, id int) (models.UserExport, error)
+	Erase(ctx context.Context, id int) error
+}
+
+// IdempotencyProvider makes retries of a request with the same
+// Idempotency-Key get the response of the first attempt.
+type IdempotencyProvider interface {
+	Begin(ctx context.Context, key models.IdempotencyKey) (models.IdempotencyKey, error)
+	Complete(ctx context.Context, key models.IdempotencyKey) error
+	Release(ctx context.Context, key models.IdempotencyKey) error
+}
+
+// EventProvider streams the changes of users and tasks as they happen.
+type EventProvider interface {
+	Subscribe(ctx context.Context, filter models.EventFilter) <-chan models.Event
+}
+
+// WebhookProvider manages the webhooks and the deliveries of events to them.
+type WebhookProvider interface {
+	Create(ctx context.Context, input models.InputWebhookCreate) (models.Webhook, error)
+	Webhooks(ctx context.Context) ([]models.Webhook, error)
+	Webhook(ctx context.Context, id int) (models.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (models.WebhookDeliveryPage, error)
+	Redeliver(ctx context.Context, webhookID int, deliveryID int64) (models.WebhookDelivery, error)
 }
 
 type Service struct {
 	UserProvider
 	TaskProvider
+	AuditProvider
+	PrivacyProvider
+	IdempotencyProvider
+	EventProvider
+	WebhookProvider
 }
 
-func NewService(log *slog.Logger, s *storage.Storage) *Service {
+func NewService(log *slog.Logger, s *storage.Storage, peopleInfo PeopleInfoProvider, bus EventBus, idempotency config.IdempotencyConfig) *Service {
 	return &Service{
-		UserProvider: NewUserService(s.UserProvider, log),
-		TaskProvider: NewTaskService(s.TaskProvider, log),
+		UserProvider:        NewUserService(s.UserProvider, s.AuditProvider, s.Transactor, peopleInfo, s.OutboxProvider, log),
+		TaskProvider:        NewTaskService(s.TaskProvider, s.AuditProvider, s.Transactor, s.OutboxProvider, log),
+		AuditProvider:       NewAuditService(s.AuditProvider, log),
+		PrivacyProvider:     NewPrivacyService(s, log),
+		IdempotencyProvider: NewIdempotencyService(s.IdempotencyProvider, idempotency, log),
+		EventProvider:       NewEventService(bus, log),
+		WebhookProvider:     NewWebhookService(s.WebhookProvider, log),
+	}
+}
+
+// endSpan marks span as failed when err is set and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
+	span.End()
 }
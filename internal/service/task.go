@@ -1,35 +1,59 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
 	"github.com/3XBAT/time-tracker/internal/storage"
 	"log/slog"
 )
 
 type TaskService struct {
 	storage storage.TaskProvider
+	events  storage.AuditProvider
+	tx      storage.Transactor
+	outbox  storage.OutboxProvider
 	log     *slog.Logger
 }
 
-func NewTaskService(s storage.TaskProvider, log *slog.Logger) *TaskService {
+func NewTaskService(s storage.TaskProvider, events storage.AuditProvider, tx storage.Transactor, outbox storage.OutboxProvider, log *slog.Logger) *TaskService {
 	return &TaskService{
 		storage: s,
+		events:  events,
+		tx:      tx,
+		outbox:  outbox,
 		log:     log,
 	}
 }
 
-func (ts *TaskService) Create(input models.InputTaskCreate) (int, error) {
+func (ts *TaskService) Create(ctx context.Context, input models.InputTaskCreate) (id int, err error) {
 	const op = "service.task.Create"
 
-	log := ts.log.With(slog.String("op", op))
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
 
 	log.Debug("received request to create task", slog.Any("input", input))
 	log.Info("starting create task")
 
-	id, err := ts.storage.Create(input)
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if id, err = ts.storage.Create(ctx, input); err != nil {
+			return err
+		}
+		created, err := ts.storage.TaskById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := ts.outbox.Add(ctx, taskEvent(models.EventTaskStarted, created)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ts.events, models.AuditActionCreate, models.AuditEntityTask, id, nil, created)
+	})
 	if err != nil {
-		log.Warn("failed creating task:", err)
+		log.Warn("failed creating task", slog.String("error", err.Error()))
 		return id, err
 	}
 
@@ -39,45 +63,119 @@ func (ts *TaskService) Create(input models.InputTaskCreate) (int, error) {
 	return id, nil
 }
 
-func (ts *TaskService) Update(task models.InputTaskUpdate) error {
+func (ts *TaskService) Update(ctx context.Context, task models.InputTaskUpdate) (updated models.Task, err error) {
 	const op = "service.task.Update"
 
-	log := ts.log.With(slog.String("op", op))
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
 
 	log.Debug("received request to update task", slog.Any("task", task))
 	log.Info("trying to update task")
 
-	err := ts.storage.Update(task)
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := ts.storage.TaskById(ctx, task.Id)
+		if err != nil {
+			return err
+		}
+		if err = ts.storage.Update(ctx, task); err != nil {
+			return err
+		}
+		if updated, err = ts.storage.TaskById(ctx, task.Id); err != nil {
+			return err
+		}
+		if err := ts.outbox.Add(ctx, taskEvent(models.EventTaskStopped, updated)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ts.events, models.AuditActionUpdate, models.AuditEntityTask, task.Id, before, updated)
+	})
 	if err != nil {
 		if errors.Is(err, storage.ErrTaskEnded) {
 			log.Warn(err.Error())
-			return err
+			return models.Task{}, err
 		}
 		if errors.Is(err, storage.ErrTaskNotFound) {
 			log.Warn(err.Error())
-			return err
+			return models.Task{}, err
 		}
-		log.Warn("failed updating task:", err)
-		return err
+		log.Warn("failed updating task", slog.String("error", err.Error()))
+		return models.Task{}, err
 	}
 
-	log.Debug("successfully updated task", slog.Any("task", task))
+	log.Debug("successfully updated task", slog.Any("task", updated))
 	log.Info("task updated successfully")
 
-	return err
+	return updated, nil
+}
+
+// Patch changes the name or the period of a task, the end time only once
+// it is finished.
+func (ts *TaskService) Patch(ctx context.Context, taskID int, patch models.InputTaskPatch) (updated models.Task, err error) {
+	const op = "service.task.Patch"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
+
+	log.Debug("received request to patch task", slog.Int("id", taskID), slog.Any("patch", patch))
+	log.Info("trying to patch task")
+
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := ts.storage.TaskById(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if err = ts.storage.Patch(ctx, taskID, patch); err != nil {
+			return err
+		}
+		if updated, err = ts.storage.TaskById(ctx, taskID); err != nil {
+			return err
+		}
+		if err := ts.outbox.Add(ctx, taskEvent(models.EventTaskUpdated, updated)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ts.events, models.AuditActionUpdate, models.AuditEntityTask, taskID, before, updated)
+	})
+	if err != nil {
+		log.Warn("failed patching task", slog.String("error", err.Error()))
+		return models.Task{}, err
+	}
+
+	log.Debug("successfully patched task", slog.Any("task", updated))
+	log.Info("task patched successfully")
+
+	return updated, nil
 }
 
-func (ts *TaskService) Delete(task models.InputTaskDelete) error {
+func (ts *TaskService) Delete(ctx context.Context, task models.InputTaskDelete) (err error) {
 	const op = "service.task.Delete"
 
-	log := ts.log.With(slog.String("op", op))
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
 
 	log.Debug("Received request to delete task", slog.Any("task", task))
 	log.Info("trying to delete task")
 
-	err := ts.storage.Delete(task)
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := ts.storage.TaskById(ctx, task.TaskID)
+		if err != nil {
+			return err
+		}
+		if err = ts.storage.Delete(ctx, task); err != nil {
+			return err
+		}
+		if err := ts.outbox.Add(ctx, taskEvent(models.EventTaskDeleted, before)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ts.events, models.AuditActionDelete, models.AuditEntityTask, task.TaskID, before, nil)
+	})
 	if err != nil {
-		log.Warn("failed deleting task:", err.Error())
+		log.Warn("failed deleting task", slog.String("error", err.Error()))
+		return err
 	}
 
 	log.Debug("successfully deleted task", slog.Any("task", task))
@@ -86,21 +184,177 @@ func (ts *TaskService) Delete(task models.InputTaskDelete) error {
 	return err
 }
 
-func (ts *TaskService) Tasks(input models.InputTask) ([]models.OutputTask, error) {
+func (ts *TaskService) Tasks(ctx context.Context, input models.InputTask) (page models.TaskPage, err error) {
 	const op = "service.task.Tasks"
 
-	log := ts.log.With(slog.String("op", op))
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
 
 	log.Debug("received request to get tasks", slog.Any("input", input))
 	log.Info("trying to get tasks")
 
-	tasks, err := ts.storage.Tasks(input)
+	page, err = ts.storage.Tasks(ctx, input)
 	if err != nil {
-		log.Warn("failed getting tasks:", err)
-		return nil, err
+		log.Warn("failed getting tasks", slog.String("error", err.Error()))
+		return page, err
 	}
 
-	log.Debug("successfully retrieved tasks", slog.Int("count", len(tasks)))
+	log.Debug("successfully retrieved tasks", slog.Int("count", len(page.Tasks)), slog.Int("total", page.Total))
 	log.Info("getting tasks successfully")
+	return page, nil
+}
+
+func (ts *TaskService) Restore(ctx context.Context, taskID int) (err error) {
+	const op = "service.task.Restore"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
+
+	log.Debug("received request to restore task", slog.Int("id", taskID))
+	log.Info("trying to restore task")
+
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := ts.storage.Restore(ctx, taskID); err != nil {
+			return err
+		}
+		after, err := ts.storage.TaskById(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if err := ts.outbox.Add(ctx, taskEvent(models.EventTaskRestored, after)); err != nil {
+			return err
+		}
+		return recordChange(ctx, ts.events, models.AuditActionRestore, models.AuditEntityTask, taskID, nil, after)
+	})
+	if err != nil {
+		log.Warn("failed restoring task", slog.String("error", err.Error()))
+		return err
+	}
+
+	log.Info("task restored")
+
+	return nil
+}
+
+func (ts *TaskService) TaskById(ctx context.Context, taskID int) (task models.Task, err error) {
+	const op = "service.task.TaskById"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
+
+	log.Debug("received request to get task", slog.Int("id", taskID))
+
+	task, err = ts.storage.TaskById(ctx, taskID)
+	if err != nil {
+		log.Warn("failed getting task", slog.String("error", err.Error()))
+		return task, err
+	}
+
+	return task, nil
+}
+
+// TasksByUsers loads the tasks of several users at once.
+func (ts *TaskService) TasksByUsers(ctx context.Context, userIDs []int, period models.TaskPeriod) (tasks []models.Task, err error) {
+	const op = "service.task.TasksByUsers"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
+
+	log.Debug("received request to get tasks of users", slog.Any("user_ids", userIDs))
+
+	tasks, err = ts.storage.TasksByUsers(ctx, userIDs, period)
+	if err != nil {
+		log.Warn("failed getting tasks of users", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	log.Debug("successfully retrieved tasks of users", slog.Int("count", len(tasks)))
 	return tasks, nil
 }
+
+// BatchError reports the operation that failed an atomic batch.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch applies the operations in order and returns their results. An
+// atomic batch runs in one transaction and stops at the first failed
+// operation, which is returned as a *BatchError with nothing applied.
+// Otherwise every operation runs in its own transaction and its failure is
+// only reported in its result.
+func (ts *TaskService) Batch(ctx context.Context, ops []models.TaskOperation, atomic bool) (results []models.TaskOperationResult, err error) {
+	const op = "service.task.Batch"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ts.log).With(slog.String("op", op))
+
+	log.Debug("received request to apply task batch", slog.Int("operations", len(ops)), slog.Bool("atomic", atomic))
+	log.Info("trying to apply task batch")
+
+	results = make([]models.TaskOperationResult, len(ops))
+	if !atomic {
+		failed := 0
+		for i, o := range ops {
+			if results[i] = ts.apply(ctx, o); results[i].Err != nil {
+				failed++
+			}
+		}
+		log.Info("task batch applied", slog.Int("failed", failed))
+		return results, nil
+	}
+
+	err = ts.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for i, o := range ops {
+			if results[i] = ts.apply(ctx, o); results[i].Err != nil {
+				return &BatchError{Index: i, Err: results[i].Err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn("failed applying task batch", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	log.Info("task batch applied")
+
+	return results, nil
+}
+
+// apply runs a single batch operation, within the transaction of the
+// batch when ctx carries one.
+func (ts *TaskService) apply(ctx context.Context, o models.TaskOperation) (res models.TaskOperationResult) {
+	switch o.Op {
+	case models.TaskOpCreate:
+		res.TaskID, res.Err = ts.Create(ctx, models.InputTaskCreate{UserID: o.UserID, Name: o.Name, StartPeriod: o.StartTime})
+	case models.TaskOpStop:
+		var task models.Task
+		task, res.Err = ts.Update(ctx, models.InputTaskUpdate{Id: o.TaskID, UserID: o.UserID, Version: o.Version})
+		res.TaskID, res.Version = o.TaskID, task.Version
+	case models.TaskOpDelete:
+		res.TaskID = o.TaskID
+		res.Err = ts.Delete(ctx, models.InputTaskDelete{UserID: o.UserID, TaskID: o.TaskID, Version: o.Version})
+	default:
+		res.Err = fmt.Errorf("unknown task operation %q", o.Op)
+	}
+	return res
+}
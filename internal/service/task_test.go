@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/audit"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"github.com/3XBAT/time-tracker/internal/storage/storagetest"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// newBatchTest returns a task service over the test database and a user
+// to create tasks for.
+func newBatchTest(t *testing.T) (*TaskService, *storage.Storage, int) {
+	t.Helper()
+
+	s := storage.NewStorage(storagetest.DB(t, "users", "tasks", "outbox"))
+	userID, err := s.UserProvider.Create(context.Background(), models.User{PassportNumber: "1234 567890", Name: "Иван", Surname: "Иванов"})
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+
+	ts := NewTaskService(s.TaskProvider, s.AuditProvider, s.Transactor, s.OutboxProvider, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return ts, s, userID
+}
+
+// batchOps creates two tasks and then stops a task that does not exist.
+func batchOps(userID int) []models.TaskOperation {
+	return []models.TaskOperation{
+		{Op: models.TaskOpCreate, UserID: userID, Name: "review"},
+		{Op: models.TaskOpCreate, UserID: userID, Name: "deploy"},
+		{Op: models.TaskOpStop, UserID: userID, TaskID: 1000},
+	}
+}
+
+func TestBatchAtomicRollsBack(t *testing.T) {
+	// the audit log can not be emptied, the events of the test are told
+	// apart by their actor
+	actor := fmt.Sprintf("batch-test-%d", time.Now().UnixNano())
+	ctx := audit.WithOrigin(context.Background(), audit.Origin{Actor: actor})
+	ts, s, userID := newBatchTest(t)
+
+	results, err := ts.Batch(ctx, batchOps(userID), true)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, storage.ErrTaskNotFound) {
+		t.Fatalf("Batch error = %v, want operation 2 failing with ErrTaskNotFound", err)
+	}
+	if results != nil {
+		t.Fatalf("results %+v of a failed atomic batch", results)
+	}
+
+	tasks, err := s.TaskProvider.TasksByUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("TasksByUser: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Fatalf("tasks %+v are left by the failed batch", tasks)
+	}
+	if last, err := s.OutboxProvider.Last(ctx); err != nil || last != 0 {
+		t.Fatalf("outbox Last = %d, %v, want no events", last, err)
+	}
+	page, err := s.AuditProvider.Events(ctx, models.AuditFilter{Actor: actor, Limit: 10})
+	if err != nil || len(page.Events) != 0 {
+		t.Fatalf("audit events %+v, %v, want none", page.Events, err)
+	}
+}
+
+func TestBatchReportsFailuresPerOperation(t *testing.T) {
+	ctx := context.Background()
+	ts, s, userID := newBatchTest(t)
+
+	results, err := ts.Batch(ctx, batchOps(userID), false)
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("%d results, want one per operation", len(results))
+	}
+	for i, res := range results[:2] {
+		if res.Err != nil || res.TaskID == 0 {
+			t.Errorf("operation %d = %+v, want the task created", i, res)
+		}
+	}
+	if !errors.Is(results[2].Err, storage.ErrTaskNotFound) {
+		t.Errorf("operation 2 error = %v, want ErrTaskNotFound", results[2].Err)
+	}
+
+	tasks, err := s.TaskProvider.TasksByUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("TasksByUser: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("%d tasks, want the 2 created before the failure", len(tasks))
+	}
+	if last, err := s.OutboxProvider.Last(ctx); err != nil || last != 2 {
+		t.Fatalf("outbox Last = %d, %v, want the events of the 2 tasks", last, err)
+	}
+}
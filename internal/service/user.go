@@ -1,54 +1,77 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	api2 "github.com/3XBAT/time-tracker/internal/api"
-	"github.com/3XBAT/time-tracker/internal/config"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
 	"github.com/3XBAT/time-tracker/internal/storage"
 	"log/slog"
 	"strings"
 )
 
 type UserService struct {
-	storage storage.UserProvider
-	log     *slog.Logger
+	storage    storage.UserProvider
+	events     storage.AuditProvider
+	tx         storage.Transactor
+	peopleInfo PeopleInfoProvider
+	outbox     storage.OutboxProvider
+	log        *slog.Logger
 }
 
-func NewUserService(s storage.UserProvider, log *slog.Logger) *UserService {
+func NewUserService(
+	s storage.UserProvider,
+	events storage.AuditProvider,
+	tx storage.Transactor,
+	peopleInfo PeopleInfoProvider,
+	outbox storage.OutboxProvider,
+	log *slog.Logger,
+) *UserService {
 	return &UserService{
-		storage: s,
-		log:     log,
+		storage:    s,
+		events:     events,
+		tx:         tx,
+		peopleInfo: peopleInfo,
+		outbox:     outbox,
+		log:        log,
 	}
 }
 
-func (us *UserService) Users(params models.QueryParams) ([]models.User, error) {
+func (us *UserService) Users(ctx context.Context, params models.QueryParams) (page models.UserPage, err error) {
 	const op = "service.user.Users"
-	log := us.log.With(slog.String("op", op))
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
 
 	log.Debug("Received request with params", slog.Any("params", params))
 	log.Info("attempting to get users")
 
-	users, err := us.storage.Users(params)
+	page, err = us.storage.Users(ctx, params)
 	if err != nil {
 		log.Warn(err.Error())
-		return nil, err
+		return page, err
 	}
 
-	log.Debug("Successfully retrieved users", slog.Any("users", users))
+	log.Debug("Successfully retrieved users", slog.Any("users", page.Users), slog.Int("total", page.Total))
 	log.Info("getting users was successful")
-	return users, nil
+	return page, nil
 }
 
-func (us *UserService) UserById(id int) (models.User, error) {
+func (us *UserService) UserById(ctx context.Context, id int) (user models.User, err error) {
 	const op = "service.user.UserById"
-	log := us.log.With(slog.String("op", op))
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
 
 	log.Debug("Received request for user ID", slog.Int("id", id))
 	log.Info("attempting to get user")
 
-	user, err := us.storage.UserByID(id)
+	user, err = us.storage.UserByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Warn(err.Error())
@@ -63,29 +86,70 @@ func (us *UserService) UserById(id int) (models.User, error) {
 	return user, nil
 }
 
-func (us *UserService) Create(passportNumber string) (id int, err error) {
+// UsersByIDs loads several users at once, the users that do not exist are
+// left out.
+func (us *UserService) UsersByIDs(ctx context.Context, ids []int) (users []models.User, err error) {
+	const op = "service.user.UsersByIDs"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
+
+	log.Debug("Received request for user IDs", slog.Any("ids", ids))
+
+	users, err = us.storage.UsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Debug("Successfully retrieved users", slog.Int("count", len(users)))
+
+	return users, nil
+}
+
+func (us *UserService) Create(ctx context.Context, passportNumber string) (id int, err error) {
 	const op = "service.user.CreateUser"
-	log := us.log.With(slog.String("op", op))
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
 
 	log.Debug("Received request to create user with passport number", slog.String("passportNumber", passportNumber))
 	log.Info("attempting to get user info")
 
-	psprtParams := strings.Split(passportNumber, " ")
+	if !models.PassportPattern.MatchString(passportNumber) {
+		log.Warn("rejected malformed passport number")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidPassport)
+	}
 
-	cfg := config.MustLoad()
-	api := api2.NewApiClient(&cfg)
+	// the passport number is written as its series followed by the number
+	psprtParams := strings.Split(passportNumber, " ")
 
-	user, err := api.UserInfo(psprtParams[0], psprtParams[1])
+	user, err := us.peopleInfo.UserInfo(ctx, psprtParams[1], psprtParams[0])
 	if err != nil {
 		log.Error(err.Error())
 		return 0, err
 	}
-	if _, err := us.storage.UserByID(user.ID); err == nil {
-		log.Error(err.Error())
+	if _, err := us.storage.UserByID(ctx, user.ID); err == nil {
+		log.Warn(storage.ErrUserExists.Error(), slog.Int("id", user.ID))
 		return 0, storage.ErrUserExists
 	}
 
-	id, err = us.storage.Create(*user)
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if id, err = us.storage.Create(ctx, *user); err != nil {
+			return err
+		}
+		created, err := us.storage.UserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := us.outbox.Add(ctx, userEvent(models.EventUserCreated, created)); err != nil {
+			return err
+		}
+		return recordChange(ctx, us.events, models.AuditActionCreate, models.AuditEntityUser, id, nil, created)
+	})
 	if err != nil {
 		log.Error(err.Error())
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -97,49 +161,76 @@ func (us *UserService) Create(passportNumber string) (id int, err error) {
 	return id, nil
 }
 
-func (us *UserService) Update(user models.UpdateUserInput, id int) error {
+func (us *UserService) Update(ctx context.Context, user models.UpdateUserInput, id int) (updated models.User, err error) {
 	const op = "service.user.UpdateUser"
-	log := us.log.With(slog.String("op", op))
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
 
 	log.Debug("Received request to update user", slog.Any("user", user), slog.Int("id", id))
 
-	if _, err := us.storage.UserByID(id); err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Warn(err.Error())
+	log.Info("attempting to update user")
+
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := us.storage.UserByID(ctx, id)
+		if err != nil {
 			return err
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		if err = us.storage.Update(ctx, user, id); err != nil {
+			return err
+		}
+		if updated, err = us.storage.UserByID(ctx, id); err != nil {
+			return err
+		}
+		if err := us.outbox.Add(ctx, userEvent(models.EventUserUpdated, updated)); err != nil {
+			return err
+		}
+		return recordChange(ctx, us.events, models.AuditActionUpdate, models.AuditEntityUser, id, before, updated)
+	})
+	if errors.Is(err, storage.ErrUserNotFound) || errors.Is(err, storage.ErrVersionMismatch) || errors.Is(err, storage.ErrNothingToUpdate) {
+		log.Warn(err.Error())
+		return models.User{}, err
 	}
-
-	log.Info("attempting to update user")
-
-	err := us.storage.Update(user, id)
 	if err != nil {
 		log.Error(err.Error())
-		return err
+		return models.User{}, err
 	}
 
 	log.Debug("Successfully updated user", slog.Int("id", id))
 	log.Info("updating user was successful")
-	return nil
+	return updated, nil
 }
 
-func (us *UserService) Delete(id int) error {
+func (us *UserService) Delete(ctx context.Context, id, version int) (err error) {
 	const op = "service.user.Delete"
-	log := us.log.With(slog.String("op", op))
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
 
 	log.Debug("Received request to delete user", slog.Int("id", id))
 	log.Info("attempting to delete user")
 
-	if _, err := us.storage.UserByID(id); err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			log.Info(err.Error())
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := us.storage.UserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = us.storage.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		if err := us.outbox.Add(ctx, userEvent(models.EventUserDeleted, before)); err != nil {
 			return err
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		return recordChange(ctx, us.events, models.AuditActionDelete, models.AuditEntityUser, id, before, nil)
+	})
+	if errors.Is(err, storage.ErrUserNotFound) || errors.Is(err, storage.ErrVersionMismatch) {
+		log.Info(err.Error())
+		return err
 	}
-
-	err := us.storage.Delete(id)
 	if err != nil {
 		log.Error("error deleting user: " + err.Error())
 		return err
@@ -149,3 +240,41 @@ func (us *UserService) Delete(id int) error {
 	log.Info("deleting user was successful")
 	return nil
 }
+
+func (us *UserService) Restore(ctx context.Context, id int) (err error) {
+	const op = "service.user.Restore"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, us.log).With(slog.String("op", op))
+
+	log.Debug("Received request to restore user", slog.Int("id", id))
+	log.Info("attempting to restore user")
+
+	err = us.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := us.storage.Restore(ctx, id); err != nil {
+			return err
+		}
+		after, err := us.storage.UserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := us.outbox.Add(ctx, userEvent(models.EventUserRestored, after)); err != nil {
+			return err
+		}
+		return recordChange(ctx, us.events, models.AuditActionRestore, models.AuditEntityUser, id, nil, after)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info(err.Error())
+			return err
+		}
+		log.Error("error restoring user: " + err.Error())
+		return err
+	}
+
+	log.Debug("Successfully restored user", slog.Int("id", id))
+	log.Info("restoring user was successful")
+	return nil
+}
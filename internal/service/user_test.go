@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/api"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCreateAsksPeopleInfoForSeriesAndNumber(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{API: config.APIConfig{ExternalURL: srv.URL, Timeout: time.Second}}
+	us := &UserService{
+		peopleInfo: api.NewApiClient(cfg, metrics.New()),
+		log:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if _, err := us.Create(context.Background(), "1234 567890"); !errors.Is(err, api.ErrPersonNotFound) {
+		t.Fatalf("Create error = %v, want ErrPersonNotFound", err)
+	}
+	if got := query.Get("passportSerie"); got != "1234" {
+		t.Errorf("passportSerie = %q, want 1234", got)
+	}
+	if got := query.Get("passportNumber"); got != "567890" {
+		t.Errorf("passportNumber = %q, want 567890", got)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/logger"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"log/slog"
+)
+
+// secretPrefix marks the generated webhook secrets.
+const secretPrefix = "whsec_"
+
+type WebhookService struct {
+	storage storage.WebhookProvider
+	log     *slog.Logger
+}
+
+func NewWebhookService(s storage.WebhookProvider, log *slog.Logger) *WebhookService {
+	return &WebhookService{
+		storage: s,
+		log:     log,
+	}
+}
+
+// Create subscribes the URL to the events, a secret is generated unless
+// one is given. The returned webhook is the only one carrying the secret.
+func (ws *WebhookService) Create(ctx context.Context, input models.InputWebhookCreate) (webhook models.Webhook, err error) {
+	const op = "service.webhook.Create"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to create webhook", slog.String("url", input.URL), slog.Any("event_types", input.EventTypes))
+	log.Info("trying to create webhook")
+
+	secret := input.Secret
+	if secret == "" {
+		b := make([]byte, 24)
+		_, _ = rand.Read(b)
+		secret = secretPrefix + base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	webhook, err = ws.storage.Create(ctx, models.Webhook{URL: input.URL, EventTypes: input.EventTypes, Secret: secret})
+	if err != nil {
+		log.Warn("failed creating webhook", slog.String("error", err.Error()))
+		return webhook, err
+	}
+
+	log.Info("webhook created", slog.Int("id", webhook.ID))
+
+	return webhook, nil
+}
+
+func (ws *WebhookService) Webhooks(ctx context.Context) (webhooks []models.Webhook, err error) {
+	const op = "service.webhook.Webhooks"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to get webhooks")
+
+	webhooks, err = ws.storage.Webhooks(ctx)
+	if err != nil {
+		log.Warn("failed getting webhooks", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (ws *WebhookService) Webhook(ctx context.Context, id int) (webhook models.Webhook, err error) {
+	const op = "service.webhook.Webhook"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to get webhook", slog.Int("id", id))
+
+	webhook, err = ws.storage.WebhookByID(ctx, id)
+	if err != nil {
+		log.Warn("failed getting webhook", slog.String("error", err.Error()))
+		return webhook, err
+	}
+
+	return webhook, nil
+}
+
+func (ws *WebhookService) Delete(ctx context.Context, id int) (err error) {
+	const op = "service.webhook.Delete"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to delete webhook", slog.Int("id", id))
+	log.Info("trying to delete webhook")
+
+	if err = ws.storage.Delete(ctx, id); err != nil {
+		log.Warn("failed deleting webhook", slog.String("error", err.Error()))
+		return err
+	}
+
+	log.Info("webhook deleted")
+
+	return nil
+}
+
+func (ws *WebhookService) Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (page models.WebhookDeliveryPage, err error) {
+	const op = "service.webhook.Deliveries"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to get webhook deliveries", slog.Any("filter", filter))
+
+	if _, err = ws.storage.WebhookByID(ctx, filter.WebhookID); err != nil {
+		log.Warn("failed getting webhook", slog.String("error", err.Error()))
+		return page, err
+	}
+
+	page, err = ws.storage.Deliveries(ctx, filter)
+	if err != nil {
+		log.Warn("failed getting webhook deliveries", slog.String("error", err.Error()))
+		return page, err
+	}
+
+	log.Debug("successfully retrieved webhook deliveries", slog.Int("count", len(page.Deliveries)))
+	return page, nil
+}
+
+// Redeliver sends a delivery again, whatever its status.
+func (ws *WebhookService) Redeliver(ctx context.Context, webhookID int, deliveryID int64) (delivery models.WebhookDelivery, err error) {
+	const op = "service.webhook.Redeliver"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer func() { endSpan(span, err) }()
+
+	log := logger.FromContext(ctx, ws.log).With(slog.String("op", op))
+
+	log.Debug("received request to redeliver", slog.Int("webhook_id", webhookID), slog.Int64("delivery_id", deliveryID))
+	log.Info("trying to redeliver webhook delivery")
+
+	delivery, err = ws.storage.Redeliver(ctx, webhookID, deliveryID)
+	if err != nil {
+		log.Warn("failed redelivering", slog.String("error", err.Error()))
+		return delivery, err
+	}
+
+	log.Info("webhook delivery queued again")
+
+	return delivery, nil
+}
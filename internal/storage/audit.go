@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const auditColumns = `id, occurred_at, actor, request_id, action, entity, entity_id,
+	COALESCE(before_state, 'null') AS before_state, COALESCE(after_state, 'null') AS after_state`
+
+type AuditStorage struct {
+	db *sqlx.DB
+}
+
+func NewAuditStorage(db *sqlx.DB) *AuditStorage {
+	return &AuditStorage{db: db}
+}
+
+// Record appends an event to the audit log, within the transaction of the
+// change it describes when ctx carries one.
+func (s *AuditStorage) Record(ctx context.Context, event models.AuditEvent) error {
+	const op = "storage.audit.Record"
+
+	query := `INSERT INTO audit_events (actor, request_id, action, entity, entity_id, before_state, after_state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := conn(ctx, s.db).ExecContext(ctx, query,
+		event.Actor, event.RequestID, event.Action, event.Entity, event.EntityID,
+		jsonArg(event.Before), jsonArg(event.After),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AuditStorage) Events(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error) {
+	const op = "storage.audit.Events"
+	var page models.AuditPage
+
+	order := sortOrder{field: ID, desc: true}
+	after, err := decodeCursor(filter.Cursor, order)
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b := &filterBuilder{}
+	if filter.Actor != "" {
+		b.conditions = append(b.conditions, "actor = "+b.arg(filter.Actor))
+	}
+	if filter.Action != "" {
+		b.conditions = append(b.conditions, "action = "+b.arg(filter.Action))
+	}
+	if filter.Entity != "" {
+		b.conditions = append(b.conditions, "entity = "+b.arg(filter.Entity))
+	}
+	if filter.EntityID != 0 {
+		b.conditions = append(b.conditions, "entity_id = "+b.arg(filter.EntityID))
+	}
+	if filter.From != nil {
+		b.conditions = append(b.conditions, "occurred_at >= "+b.arg(*filter.From))
+	}
+	if filter.To != nil {
+		b.conditions = append(b.conditions, "occurred_at < "+b.arg(*filter.To))
+	}
+	if after != nil {
+		b.conditions = append(b.conditions, "id < "+b.arg(after.ID))
+	}
+
+	// one extra row tells whether there is a next page
+	query := "SELECT " + auditColumns + " FROM audit_events" + b.where() +
+		" ORDER BY id DESC LIMIT " + b.arg(filter.Limit+1)
+
+	if err = sqlx.SelectContext(ctx, conn(ctx, s.db), &page.Events, query, b.args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(page.Events) > filter.Limit {
+		page.Events = page.Events[:filter.Limit]
+		last := page.Events[len(page.Events)-1]
+		page.NextCursor = encodeCursor(cursor{Sort: order.String(), ID: int(last.ID)})
+	}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+
+	return page, nil
+}
+
+// Redact removes the given fields from the recorded states of an entity.
+// The audit log is otherwise append-only, see the audit.redact setting in
+// the migrations.
+func (s *AuditStorage) Redact(ctx context.Context, entity string, entityID int, fields []string) error {
+	const op = "storage.audit.Redact"
+
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		if _, err := conn(ctx, s.db).ExecContext(ctx, `SELECT set_config('audit.redact', 'on', true)`); err != nil {
+			return err
+		}
+
+		query := `UPDATE audit_events
+			SET before_state = before_state - $3::text[], after_state = after_state - $3::text[]
+			WHERE entity = $1 AND entity_id = $2`
+		if _, err := conn(ctx, s.db).ExecContext(ctx, query, entity, entityID, pq.Array(fields)); err != nil {
+			return err
+		}
+
+		_, err := conn(ctx, s.db).ExecContext(ctx, `SELECT set_config('audit.redact', 'off', true)`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// jsonArg passes a JSON document as a query argument, empty ones as NULL.
+func jsonArg(doc json.RawMessage) any {
+	if doc == nil {
+		return nil
+	}
+	return string(doc)
+}
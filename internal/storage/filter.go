@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter values are matched exactly unless they start with an operator:
+//
+//	>v, >=v, <v, <=v  range
+//	contains:v        case-insensitive substring
+//	prefix:v          case-insensitive prefix
+//	in:a,b,c          any of the listed values
+const (
+	opContains = "contains:"
+	opPrefix   = "prefix:"
+	opIn       = "in:"
+)
+
+// userSearchText is the text searched by the full-text and trigram lookups,
+// it must stay identical to the expressions of the indexes in the migrations.
+const (
+	userSearchText   = `lower(name || ' ' || surname || ' ' || patronymic || ' ' || addr)`
+	userSearchVector = `to_tsvector('simple', ` + userSearchText + `)`
+)
+
+// filterField is a filterable column, numeric columns only support exact,
+// range and IN matches.
+type filterField struct {
+	column  string
+	numeric bool
+}
+
+// filterBuilder accumulates the conditions of a WHERE clause together with
+// their positional arguments.
+type filterBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (b *filterBuilder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *filterBuilder) add(f filterField, value string) error {
+	switch {
+	case strings.HasPrefix(value, opContains), strings.HasPrefix(value, opPrefix):
+		if f.numeric {
+			return fmt.Errorf("%w: %s supports only exact, range and in matches", ErrInvalidFilter, f.column)
+		}
+		pattern, ok := strings.CutPrefix(value, opContains)
+		if ok {
+			pattern = "%" + escapeLike(pattern) + "%"
+		} else {
+			pattern = escapeLike(strings.TrimPrefix(value, opPrefix)) + "%"
+		}
+		b.conditions = append(b.conditions, fmt.Sprintf("%s ILIKE %s", f.column, b.arg(pattern)))
+
+	case strings.HasPrefix(value, opIn):
+		items := strings.Split(strings.TrimPrefix(value, opIn), ",")
+		placeholders := make([]string, 0, len(items))
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				return fmt.Errorf("%w: empty value in the %s list", ErrInvalidFilter, f.column)
+			}
+			v, err := f.value(item)
+			if err != nil {
+				return err
+			}
+			placeholders = append(placeholders, b.arg(v))
+		}
+		b.conditions = append(b.conditions, fmt.Sprintf("%s IN (%s)", f.column, strings.Join(placeholders, ", ")))
+
+	default:
+		operator := "="
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if rest, ok := strings.CutPrefix(value, op); ok {
+				operator, value = op, rest
+				break
+			}
+		}
+		v, err := f.value(value)
+		if err != nil {
+			return err
+		}
+		b.conditions = append(b.conditions, fmt.Sprintf("%s %s %s", f.column, operator, b.arg(v)))
+	}
+
+	return nil
+}
+
+// search matches the words of q against the full-text index and falls back
+// to trigram similarity, so that misspelled words still find the user.
+func (b *filterBuilder) search(q string) {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return
+	}
+	ph := b.arg(q)
+	b.conditions = append(b.conditions, fmt.Sprintf(
+		"(%s @@ plainto_tsquery('simple', %s) OR %s <%% %s)",
+		userSearchVector, ph, ph, userSearchText,
+	))
+}
+
+func (b *filterBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+func (f filterField) value(s string) (interface{}, error) {
+	if !f.numeric {
+		return s, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a number, got %q", ErrInvalidFilter, f.column, s)
+	}
+	return n, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterBuilderAdd(t *testing.T) {
+	name := filterField{column: Name}
+	id := filterField{column: ID, numeric: true}
+
+	tests := []struct {
+		name      string
+		field     filterField
+		value     string
+		condition string
+		args      []interface{}
+		expectErr bool
+	}{
+		{name: "exact", field: name, value: "Ivan", condition: "name = $1", args: []interface{}{"Ivan"}},
+		{name: "exact number", field: id, value: "42", condition: "id = $1", args: []interface{}{42}},
+		{name: "greater", field: id, value: ">10", condition: "id > $1", args: []interface{}{10}},
+		{name: "greater or equal", field: id, value: ">=10", condition: "id >= $1", args: []interface{}{10}},
+		{name: "less", field: id, value: "<10", condition: "id < $1", args: []interface{}{10}},
+		{name: "less or equal", field: id, value: "<=10", condition: "id <= $1", args: []interface{}{10}},
+		{name: "text range", field: name, value: ">=M", condition: "name >= $1", args: []interface{}{"M"}},
+		{name: "contains", field: name, value: "contains:va", condition: "name ILIKE $1", args: []interface{}{"%va%"}},
+		{name: "prefix", field: name, value: "prefix:Iv", condition: "name ILIKE $1", args: []interface{}{"Iv%"}},
+		{name: "contains escapes wildcards", field: name, value: `contains:50%_off\`, condition: "name ILIKE $1", args: []interface{}{`%50\%\_off\\%`}},
+		{name: "prefix escapes wildcards", field: name, value: `prefix:a_b%`, condition: "name ILIKE $1", args: []interface{}{`a\_b\%%`}},
+		{name: "in", field: name, value: "in:Ivan, Petr,Anna", condition: "name IN ($1, $2, $3)", args: []interface{}{"Ivan", "Petr", "Anna"}},
+		{name: "in numbers", field: id, value: "in:1,2", condition: "id IN ($1, $2)", args: []interface{}{1, 2}},
+		{name: "operators only at the start", field: name, value: "a>b", condition: "name = $1", args: []interface{}{"a>b"}},
+
+		{name: "not a number", field: id, value: "abc", expectErr: true},
+		{name: "range without a number", field: id, value: ">", expectErr: true},
+		{name: "range of text on a number", field: id, value: ">=ten", expectErr: true},
+		{name: "contains on a number", field: id, value: "contains:1", expectErr: true},
+		{name: "prefix on a number", field: id, value: "prefix:1", expectErr: true},
+		{name: "empty in", field: name, value: "in:", expectErr: true},
+		{name: "empty in item", field: name, value: "in:a,,b", expectErr: true},
+		{name: "in with a non-number", field: id, value: "in:1,x", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &filterBuilder{}
+			err := b.add(tt.field, tt.value)
+			if tt.expectErr {
+				if !errors.Is(err, ErrInvalidFilter) {
+					t.Fatalf("error = %v, want ErrInvalidFilter", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(b.conditions) != 1 || b.conditions[0] != tt.condition {
+				t.Errorf("conditions = %q, want %q", b.conditions, tt.condition)
+			}
+			if !reflect.DeepEqual(b.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestFilterBuilderSearch(t *testing.T) {
+	b := &filterBuilder{}
+	b.search("   ")
+	if len(b.conditions) != 0 || b.where() != "" {
+		t.Fatalf("blank search added %q", b.conditions)
+	}
+
+	b.search("  Ivan PETROV ")
+	if !reflect.DeepEqual(b.args, []interface{}{"ivan petrov"}) {
+		t.Fatalf("args = %#v, want the lowercased query", b.args)
+	}
+	want := "(" + userSearchVector + " @@ plainto_tsquery('simple', $1) OR $1 <% " + userSearchText + ")"
+	if len(b.conditions) != 1 || b.conditions[0] != want {
+		t.Fatalf("condition = %q, want %q", b.conditions, want)
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	b, err := buildQuery(models.QueryParams{ID: ">5", Name: "prefix:Iv", Address: "in:Moscow,Kazan", Search: "ivan"})
+	if err != nil {
+		t.Fatalf("buildQuery: %v", err)
+	}
+
+	where := b.where()
+	for _, cond := range []string{"deleted_at IS NULL", "id > $1", "name ILIKE $2", "addr IN ($3, $4)", "plainto_tsquery('simple', $5)"} {
+		if !strings.Contains(where, cond) {
+			t.Errorf("where %q lacks %q", where, cond)
+		}
+	}
+	if !reflect.DeepEqual(b.args, []interface{}{5, "Iv%", "Moscow", "Kazan", "ivan"}) {
+		t.Errorf("args = %#v", b.args)
+	}
+
+	if _, err = buildQuery(models.QueryParams{ID: "contains:5"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("buildQuery with an invalid filter = %v, want ErrInvalidFilter", err)
+	}
+}
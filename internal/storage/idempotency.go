@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+const idempotencyColumns = `actor, key, request_hash, COALESCE(status_code, 0) AS status_code,
+	response_headers, response_body, created_at`
+
+type idempotencyRow struct {
+	Actor       string    `db:"actor"`
+	Key         string    `db:"key"`
+	RequestHash string    `db:"request_hash"`
+	StatusCode  int       `db:"status_code"`
+	Headers     []byte    `db:"response_headers"`
+	Body        []byte    `db:"response_body"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type IdempotencyStorage struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyStorage(db *sqlx.DB) *IdempotencyStorage {
+	return &IdempotencyStorage{db: db}
+}
+
+// Reserve claims the key for a new request. A key stored before expiredBefore,
+// or still in progress since before abandonedBefore, is claimed anew. The
+// returned flag is false when the key is held by another request, which is
+// returned instead.
+func (s *IdempotencyStorage) Reserve(ctx context.Context, key models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (models.IdempotencyKey, bool, error) {
+	const op = "storage.idempotency.Reserve"
+	var row idempotencyRow
+
+	query := `INSERT INTO idempotency_keys (actor, key, request_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (actor, key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, status_code = NULL,
+				response_headers = NULL, response_body = NULL, created_at = now()
+			WHERE idempotency_keys.created_at < $4
+				OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $5)
+		RETURNING ` + idempotencyColumns
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &row, query, key.Actor, key.Key, key.RequestHash, expiredBefore, abandonedBefore)
+	if err == nil {
+		stored, err := row.model()
+		if err != nil {
+			return stored, false, fmt.Errorf("%s: %w", op, err)
+		}
+		return stored, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "SELECT " + idempotencyColumns + " FROM idempotency_keys WHERE actor = $1 AND key = $2"
+	if err = sqlx.GetContext(ctx, conn(ctx, s.db), &row, query, key.Actor, key.Key); err != nil {
+		return key, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := row.model()
+	if err != nil {
+		return stored, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return stored, false, nil
+}
+
+// Complete stores the response of the request holding the key.
+func (s *IdempotencyStorage) Complete(ctx context.Context, key models.IdempotencyKey) error {
+	const op = "storage.idempotency.Complete"
+
+	headers, err := json.Marshal(key.Header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `UPDATE idempotency_keys
+		SET status_code = $4, response_headers = $5, response_body = $6, user_id = NULLIF($7, 0)
+		WHERE actor = $1 AND key = $2 AND request_hash = $3 AND status_code IS NULL`
+
+	_, err = conn(ctx, s.db).ExecContext(ctx, query, key.Actor, key.Key, key.RequestHash, key.StatusCode, headers, key.Body, key.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Release frees a key whose request has not completed, so that a retry
+// runs it again.
+func (s *IdempotencyStorage) Release(ctx context.Context, key models.IdempotencyKey) error {
+	const op = "storage.idempotency.Release"
+
+	query := `DELETE FROM idempotency_keys
+		WHERE actor = $1 AND key = $2 AND request_hash = $3 AND status_code IS NULL`
+
+	if _, err := conn(ctx, s.db).ExecContext(ctx, query, key.Actor, key.Key, key.RequestHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeUser removes the keys of the requests carrying the data of the user.
+func (s *IdempotencyStorage) PurgeUser(ctx context.Context, userID int) error {
+	const op = "storage.idempotency.PurgeUser"
+
+	if _, err := conn(ctx, s.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Purge removes the keys stored before the given time and returns their number.
+func (s *IdempotencyStorage) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.idempotency.Purge"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+func (r idempotencyRow) model() (models.IdempotencyKey, error) {
+	key := models.IdempotencyKey{
+		Actor:       r.Actor,
+		Key:         r.Key,
+		RequestHash: r.RequestHash,
+		StatusCode:  r.StatusCode,
+		Body:        r.Body,
+		CreatedAt:   r.CreatedAt,
+	}
+	if len(r.Headers) > 0 {
+		if err := json.Unmarshal(r.Headers, &key.Header); err != nil {
+			return key, err
+		}
+	}
+	return key, nil
+}
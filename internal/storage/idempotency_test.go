@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyPurgeUser(t *testing.T) {
+	ctx := context.Background()
+	s := NewIdempotencyStorage(storagetest.DB(t, "idempotency_keys"))
+	long := time.Now().Add(-time.Hour)
+
+	for _, key := range []models.IdempotencyKey{
+		{Actor: "a", Key: "patch", RequestHash: "1", StatusCode: 200, Body: []byte(`{"id":7,"name":"Ivan"}`), UserID: 7},
+		{Actor: "a", Key: "other", RequestHash: "2", StatusCode: 200, Body: []byte(`{"id":8}`), UserID: 8},
+		{Actor: "a", Key: "task", RequestHash: "3", StatusCode: 200, Body: []byte(`{"id":1}`)},
+	} {
+		if _, _, err := s.Reserve(ctx, key, long, long); err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+		if err := s.Complete(ctx, key); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+	}
+
+	if err := s.PurgeUser(ctx, 7); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+
+	for key, purged := range map[string]bool{"patch": true, "other": false, "task": false} {
+		_, reserved, err := s.Reserve(ctx, models.IdempotencyKey{Actor: "a", Key: key, RequestHash: "4"}, long, long)
+		if err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+		if reserved != purged {
+			t.Errorf("key %s reserved anew = %t, want %t", key, reserved, purged)
+		}
+	}
+}
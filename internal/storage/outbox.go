@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type outboxRow struct {
+	ID      int64  `db:"id"`
+	Payload []byte `db:"payload"`
+}
+
+type OutboxStorage struct {
+	db *sqlx.DB
+}
+
+func NewOutboxStorage(db *sqlx.DB) *OutboxStorage {
+	return &OutboxStorage{db: db}
+}
+
+// Add writes the event to the outbox, within the transaction of the change
+// it describes when ctx carries one. The change locks the row of the
+// entity first, so the events of an entity get their ids in the order in
+// which their transactions commit.
+func (s *OutboxStorage) Add(ctx context.Context, event models.Event) error {
+	const op = "storage.outbox.Add"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	aggregateType, aggregateID := event.Aggregate()
+	query := `INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4)`
+
+	if _, err = conn(ctx, s.db).ExecContext(ctx, query, aggregateType, aggregateID, event.Type, string(payload)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Claim takes or renews the lease of the sink for owner and returns up to
+// limit of the oldest events the sink has not accepted yet, in order of
+// id. It returns no events while another owner holds an unexpired lease.
+func (s *OutboxStorage) Claim(ctx context.Context, sink, owner string, limit int, lease time.Duration) ([]models.Event, error) {
+	const op = "storage.outbox.Claim"
+	var holder string
+
+	query := `INSERT INTO outbox_leases (sink, owner, expires_at) VALUES ($1, $2, now() + make_interval(secs => $3))
+		ON CONFLICT (sink) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+		WHERE outbox_leases.owner = EXCLUDED.owner OR outbox_leases.expires_at < now()
+		RETURNING owner`
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &holder, query, sink, owner, lease.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var rows []outboxRow
+	query = `SELECT id, payload FROM outbox
+		WHERE published_at IS NULL AND NOT ($1 = ANY(published_to))
+		ORDER BY id LIMIT $2`
+
+	if err = sqlx.SelectContext(ctx, conn(ctx, s.db), &rows, query, sink, limit); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := outboxEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+// Published records that the sink has accepted the events, an event is
+// published once every one of the given sinks has. ErrLeaseLost is
+// returned when owner no longer holds the lease of the sink, the events
+// are then handed to it again by the new owner.
+func (s *OutboxStorage) Published(ctx context.Context, sink, owner string, ids []int64, sinks []string) error {
+	const op = "storage.outbox.Published"
+
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		var holder string
+		err := sqlx.GetContext(ctx, conn(ctx, s.db), &holder, `SELECT owner FROM outbox_leases WHERE sink = $1 FOR SHARE`, sink)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrLeaseLost
+		}
+		if err != nil {
+			return err
+		}
+		if holder != owner {
+			return ErrLeaseLost
+		}
+
+		query := `UPDATE outbox SET published_to = array_append(published_to, $1),
+				published_at = CASE WHEN array_append(published_to, $1) @> $3 THEN now() END
+			WHERE id = ANY($2) AND NOT ($1 = ANY(published_to))`
+
+		_, err = conn(ctx, s.db).ExecContext(ctx, query, sink, pq.Array(ids), pq.Array(sinks))
+		return err
+	})
+	if errors.Is(err, ErrLeaseLost) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Settle marks as published the pending events that every one of the
+// given sinks has accepted, e.g. the ones that were only waiting for a
+// sink that has since been removed.
+func (s *OutboxStorage) Settle(ctx context.Context, sinks []string) (int64, error) {
+	const op = "storage.outbox.Settle"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx,
+		`UPDATE outbox SET published_at = now() WHERE published_at IS NULL AND published_to @> $1`, pq.Array(sinks))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
+// Last returns the id of the latest event written to the outbox, 0 when
+// it is empty.
+func (s *OutboxStorage) Last(ctx context.Context) (int64, error) {
+	const op = "storage.outbox.Last"
+	var last int64
+
+	if err := sqlx.GetContext(ctx, conn(ctx, s.db), &last, `SELECT COALESCE(max(id), 0) FROM outbox`); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return last, nil
+}
+
+// Tail returns, in order of id, up to limit of the events written after
+// the one with the id after, along with the ones among missing that have
+// shown up since, whatever sinks have accepted them.
+func (s *OutboxStorage) Tail(ctx context.Context, after int64, missing []int64, limit int) ([]models.Event, error) {
+	const op = "storage.outbox.Tail"
+	var rows []outboxRow
+
+	query := `SELECT id, payload FROM outbox WHERE id > $1 OR id = ANY($2) ORDER BY id LIMIT $3`
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &rows, query, after, pq.Array(missing), limit); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := outboxEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+// Purge removes the events published before the given time.
+func (s *OutboxStorage) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.outbox.Purge"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
+func outboxEvents(rows []outboxRow) ([]models.Event, error) {
+	events := make([]models.Event, 0, len(rows))
+	for _, row := range rows {
+		var event models.Event
+		if err := json.Unmarshal(row.Payload, &event); err != nil {
+			return nil, fmt.Errorf("event %d: %w", row.ID, err)
+		}
+		event.ID = uint64(row.ID)
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage/storagetest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func addTaskEvents(t *testing.T, ctx context.Context, s *OutboxStorage, taskIDs ...int) {
+	t.Helper()
+
+	for _, id := range taskIDs {
+		if err := s.Add(ctx, models.Event{Type: models.EventTaskStopped, Task: &models.Task{Id: id}}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+}
+
+func eventIDs(events []models.Event) []int64 {
+	out := make([]int64, 0, len(events))
+	for _, e := range events {
+		out = append(out, int64(e.ID))
+	}
+	return out
+}
+
+func TestOutboxRedeliversUntilPublished(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStorage(storagetest.DB(t, "outbox", "outbox_leases"))
+	sinks := []string{"file", "http"}
+
+	addTaskEvents(t, ctx, s, 10, 20, 10, 10)
+
+	claimed, err := s.Claim(ctx, "http", "a", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if got := eventIDs(claimed); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("claimed %v, want [1 2]", got)
+	}
+
+	// the sink failed, nothing is recorded and the batch comes again
+	claimed, err = s.Claim(ctx, "http", "a", 2, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if got := eventIDs(claimed); !slices.Equal(got, []int64{1, 2}) {
+		t.Fatalf("claimed again %v, want [1 2]", got)
+	}
+	if claimed[0].Task == nil || claimed[0].Task.Id != 10 {
+		t.Fatalf("claimed event %+v, want the one of task 10", claimed[0])
+	}
+
+	if err = s.Published(ctx, "http", "a", []int64{1, 2}, sinks); err != nil {
+		t.Fatalf("Published: %v", err)
+	}
+	claimed, err = s.Claim(ctx, "http", "a", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if got := eventIDs(claimed); !slices.Equal(got, []int64{3, 4}) {
+		t.Fatalf("claimed after publishing %v, want [3 4]", got)
+	}
+
+	// the other sink is not affected, the events wait for it
+	claimed, err = s.Claim(ctx, "file", "a", 10, time.Minute)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if got := eventIDs(claimed); !slices.Equal(got, []int64{1, 2, 3, 4}) {
+		t.Fatalf("file claimed %v, want [1 2 3 4]", got)
+	}
+	if n, err := s.Purge(ctx, time.Now().Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("Purge = %d, %v, want nothing purged", n, err)
+	}
+
+	if err = s.Published(ctx, "file", "a", []int64{1, 2, 3, 4}, sinks); err != nil {
+		t.Fatalf("Published: %v", err)
+	}
+	if n, err := s.Purge(ctx, time.Now().Add(time.Hour)); err != nil || n != 2 {
+		t.Fatalf("Purge = %d, %v, want the 2 events accepted by both sinks", n, err)
+	}
+}
+
+func TestOutboxLease(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStorage(storagetest.DB(t, "outbox", "outbox_leases"))
+
+	addTaskEvents(t, ctx, s, 1)
+
+	if claimed, err := s.Claim(ctx, "http", "a", 10, time.Minute); err != nil || len(claimed) != 1 {
+		t.Fatalf("Claim = %v, %v, want the event", claimed, err)
+	}
+	if claimed, err := s.Claim(ctx, "http", "b", 10, time.Minute); err != nil || claimed != nil {
+		t.Fatalf("Claim by another owner = %v, %v, want nothing", claimed, err)
+	}
+	if err := s.Published(ctx, "http", "b", []int64{1}, []string{"http"}); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Published by another owner = %v, want ErrLeaseLost", err)
+	}
+
+	// the lease of an owner that stopped renewing it expires
+	if _, err := s.db.Exec(`UPDATE outbox_leases SET expires_at = now() - interval '1 second'`); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := s.Claim(ctx, "http", "b", 10, time.Minute); err != nil || len(claimed) != 1 {
+		t.Fatalf("Claim after expiry = %v, %v, want the event", claimed, err)
+	}
+	if err := s.Published(ctx, "http", "a", []int64{1}, []string{"http"}); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Published by the former owner = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestOutboxSettle(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStorage(storagetest.DB(t, "outbox", "outbox_leases"))
+
+	addTaskEvents(t, ctx, s, 1, 2)
+
+	if _, err := s.Claim(ctx, "file", "a", 10, time.Minute); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if err := s.Published(ctx, "file", "a", []int64{1}, []string{"file", "http"}); err != nil {
+		t.Fatalf("Published: %v", err)
+	}
+
+	// http is removed from the configuration
+	if n, err := s.Settle(ctx, []string{"file"}); err != nil || n != 1 {
+		t.Fatalf("Settle = %d, %v, want the event accepted by file", n, err)
+	}
+}
+
+func TestOutboxTailPicksUpLateCommits(t *testing.T) {
+	ctx := context.Background()
+	db := storagetest.DB(t, "outbox", "outbox_leases")
+	s := NewOutboxStorage(db)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	addTaskEvents(t, context.WithValue(ctx, txKey{}, tx), s, 1)
+	addTaskEvents(t, ctx, s, 2)
+
+	tail, err := s.Tail(ctx, 0, nil, 10)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if got := eventIDs(tail); !slices.Equal(got, []int64{2}) {
+		t.Fatalf("tail before the commit %v, want [2]", got)
+	}
+
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tail, err = s.Tail(ctx, 2, []int64{1}, 10)
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if got := eventIDs(tail); !slices.Equal(got, []int64{1}) {
+		t.Fatalf("tail after the commit %v, want [1]", got)
+	}
+	if last, err := s.Last(ctx); err != nil || last != 2 {
+		t.Fatalf("Last = %d, %v, want 2", last, err)
+	}
+}
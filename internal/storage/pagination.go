@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	sortAsc  = "asc"
+	sortDesc = "desc"
+)
+
+// cursorValue is the type of the values a sort field has in cursors.
+type cursorValue int
+
+const (
+	// cursorNone is for sorting by id, the id alone is the position.
+	cursorNone cursorValue = iota
+	cursorString
+	// cursorTime is an RFC 3339 timestamp.
+	cursorTime
+	cursorNumber
+)
+
+// sortField is a column or expression a listing can be sorted by.
+type sortField struct {
+	expr  string
+	value cursorValue
+}
+
+// sortOrder is a whitelisted sort field with its direction, written as
+// "field" or "field:asc|desc" in requests.
+type sortOrder struct {
+	field string
+	desc  bool
+	value cursorValue
+}
+
+func parseSort(s string, allowed map[string]sortField, def sortOrder) (sortOrder, error) {
+	if s == "" {
+		def.value = allowed[def.field].value
+		return def, nil
+	}
+
+	field, dir, _ := strings.Cut(s, ":")
+	f, ok := allowed[field]
+	if !ok {
+		return sortOrder{}, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, field)
+	}
+
+	switch dir {
+	case "", sortAsc:
+		return sortOrder{field: field, value: f.value}, nil
+	case sortDesc:
+		return sortOrder{field: field, desc: true, value: f.value}, nil
+	}
+	return sortOrder{}, fmt.Errorf("%w: unknown direction %q", ErrInvalidSort, dir)
+}
+
+func (o sortOrder) String() string {
+	if o.desc {
+		return o.field + ":" + sortDesc
+	}
+	return o.field + ":" + sortAsc
+}
+
+// keyset returns the condition selecting rows after the cursor position
+// and the matching ORDER BY clause, id breaks ties between equal values.
+func (o sortOrder) keyset(expr string, valueArg, idArg int) (where, orderBy string) {
+	op, dir := ">", "ASC"
+	if o.desc {
+		op, dir = "<", "DESC"
+	}
+	where = fmt.Sprintf("(%s, id) %s ($%d, $%d)", expr, op, valueArg, idArg)
+	orderBy = fmt.Sprintf(" ORDER BY %s %s, id %s", expr, dir, dir)
+	return where, orderBy
+}
+
+// cursor is the position after the last row of a page. It is handed to
+// clients as an opaque string and is only valid for the sort it was made for.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, order sortOrder) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != order.String() {
+		return nil, fmt.Errorf("%w: it was issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+	if err := c.checkValue(order.value); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, err)
+	}
+
+	return &c, nil
+}
+
+// checkValue makes sure the value of a cursor can be compared with the sort
+// field, timestamps are parsed so that they are passed on as such.
+func (c *cursor) checkValue(kind cursorValue) error {
+	switch kind {
+	case cursorString:
+		if _, ok := c.Value.(string); ok {
+			return nil
+		}
+	case cursorTime:
+		if s, ok := c.Value.(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return fmt.Errorf("value %q is not a timestamp", s)
+			}
+			c.Value = t
+			return nil
+		}
+	case cursorNumber:
+		if _, ok := c.Value.(float64); ok {
+			return nil
+		}
+	default:
+		if c.Value == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("value %v does not match the sort field", c.Value)
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSort(t *testing.T) {
+	def := sortOrder{field: "duration", desc: true}
+
+	tests := []struct {
+		sort      string
+		want      sortOrder
+		expectErr bool
+	}{
+		{sort: "", want: sortOrder{field: "duration", desc: true, value: cursorNumber}},
+		{sort: "name", want: sortOrder{field: "name", value: cursorString}},
+		{sort: "name:asc", want: sortOrder{field: "name", value: cursorString}},
+		{sort: "start_time:desc", want: sortOrder{field: "start_time", desc: true, value: cursorTime}},
+		{sort: "user_id", expectErr: true},
+		{sort: "name:up", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSort(tt.sort, taskSortFields, def)
+		if tt.expectErr {
+			if !errors.Is(err, ErrInvalidSort) {
+				t.Errorf("parseSort(%q) error = %v, want ErrInvalidSort", tt.sort, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSort(%q) = %+v, %v, want %+v", tt.sort, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	byName := sortOrder{field: "name", value: cursorString}
+	byStart := sortOrder{field: "start_time", value: cursorTime}
+	byDuration := sortOrder{field: "duration", desc: true, value: cursorNumber}
+	byID := sortOrder{field: ID, desc: true}
+
+	raw := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+	start := time.Date(2024, 7, 15, 13, 35, 35, 481207000, time.UTC)
+
+	tests := []struct {
+		name      string
+		cursor    string
+		order     sortOrder
+		want      any
+		expectErr bool
+	}{
+		{name: "name", cursor: encodeCursor(cursor{Sort: "name:asc", Value: "review", ID: 3}), order: byName, want: "review"},
+		{name: "start time", cursor: encodeCursor(cursor{Sort: "start_time:asc", Value: start.Format(time.RFC3339Nano), ID: 3}), order: byStart, want: start},
+		{name: "duration", cursor: encodeCursor(cursor{Sort: "duration:desc", Value: 5400.5, ID: 3}), order: byDuration, want: 5400.5},
+		{name: "id", cursor: encodeCursor(cursor{Sort: "id:desc", ID: 3}), order: byID, want: nil},
+
+		{name: "number for name", cursor: raw(`{"s":"name:asc","v":1,"id":3}`), order: byName, expectErr: true},
+		{name: "object for name", cursor: raw(`{"s":"name:asc","v":{"a":1},"id":3}`), order: byName, expectErr: true},
+		{name: "no value for name", cursor: raw(`{"s":"name:asc","id":3}`), order: byName, expectErr: true},
+		{name: "string for duration", cursor: raw(`{"s":"duration:desc","v":"1h","id":3}`), order: byDuration, expectErr: true},
+		{name: "not a timestamp", cursor: raw(`{"s":"start_time:asc","v":"yesterday","id":3}`), order: byStart, expectErr: true},
+		{name: "number for start time", cursor: raw(`{"s":"start_time:asc","v":1721050535,"id":3}`), order: byStart, expectErr: true},
+		{name: "value for id", cursor: raw(`{"s":"id:desc","v":"x","id":3}`), order: byID, expectErr: true},
+		{name: "other sort", cursor: encodeCursor(cursor{Sort: "name:desc", Value: "review", ID: 3}), order: byName, expectErr: true},
+		{name: "id not a number", cursor: raw(`{"s":"id:desc","id":"3"}`), order: byID, expectErr: true},
+		{name: "not json", cursor: raw(`name:asc`), order: byName, expectErr: true},
+		{name: "not base64", cursor: "%%%", order: byName, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor, tt.order)
+			if tt.expectErr {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("error = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.ID != 3 {
+				t.Errorf("id = %d, want 3", got.ID)
+			}
+			if want, ok := tt.want.(time.Time); ok {
+				if v, ok := got.Value.(time.Time); !ok || !v.Equal(want) {
+					t.Errorf("value = %#v, want %s", got.Value, want)
+				}
+			} else if got.Value != tt.want {
+				t.Errorf("value = %#v, want %#v", got.Value, tt.want)
+			}
+		})
+	}
+
+	if c, err := decodeCursor("", byName); c != nil || err != nil {
+		t.Errorf("empty cursor = %+v, %v, want the first page", c, err)
+	}
+}
@@ -1,27 +1,67 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/XSAM/otelsql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"log/slog"
+	"time"
 )
 
-func NewPostgresDB(cfg config.Config) (*sqlx.DB, error) {
+const maxConnectBackoff = 30 * time.Second
+
+// NewPostgresDB opens the connection pool and waits for the database to
+// become reachable, retrying with exponential backoff.
+func NewPostgresDB(ctx context.Context, cfg config.Config, log *slog.Logger) (*sqlx.DB, error) {
 	const op = "storage.postgres.NewStorageDB"
 
-	db, err := sqlx.Open("postgres",
+	// otelsql wraps the driver so that every query is traced as a child
+	// of the span carried by the query context.
+	sqlDB, err := otelsql.Open("postgres",
 		fmt.Sprintf("port=%s user=%s host=%s dbname=%s password=%s sslmode=%s",
 			cfg.DB.Port, cfg.DB.Username, cfg.DB.Host, cfg.DB.DBName, cfg.DB.Password, cfg.DB.SSLMode),
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
 	)
 
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	db := sqlx.NewDb(sqlDB, "postgres")
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+
+	backoff := cfg.DB.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= cfg.DB.ConnectAttempts {
+			break
+		}
+
+		log.Warn("database is not reachable, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", op, ctx.Err())
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
-	return db, nil
+
+	db.Close()
+	return nil, fmt.Errorf("%s: %w", op, err)
 }
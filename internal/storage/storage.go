@@ -1,42 +1,122 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 var (
-	ErrUserNotFound = errors.New("users not found")
-	ErrTaskNotFound = errors.New("tasks not found")
-	ErrTaskEnded    = errors.New("task already finished")
-	ErrUserExists   = errors.New("user already exists")
-	ErrBadRequest   = errors.New("bad request")
+	ErrUserNotFound    = errors.New("users not found")
+	ErrTaskNotFound    = errors.New("tasks not found")
+	ErrTaskEnded       = errors.New("task already finished")
+	ErrTaskRunning     = errors.New("task is still running")
+	ErrInvalidPeriod   = errors.New("task would end before it starts")
+	ErrUserExists      = errors.New("user already exists")
+	ErrBadRequest      = errors.New("bad request")
+	ErrInvalidSort     = errors.New("invalid sort")
+	ErrInvalidFilter   = errors.New("invalid filter")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrNothingToUpdate = errors.New("nothing to update")
+	// ErrVersionMismatch means the record was changed since the client read it.
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	// ErrLeaseLost means another instance has taken over relaying to a sink.
+	ErrLeaseLost = errors.New("outbox sink lease lost")
 )
 
 type UserProvider interface {
-	Users(params models.QueryParams) ([]models.User, error) //параметры нужны для фильтрации, если они пусты, то просто выводим все записи
-	UserByID(id int) (models.User, error)
-	Create(user models.User) (int, error)
-	Update(user models.UpdateUserInput, id int) error
-	Delete(id int) error
+	Users(ctx context.Context, params models.QueryParams) (models.UserPage, error) //параметры нужны для фильтрации, если они пусты, то просто выводим все записи
+	UserByID(ctx context.Context, id int) (models.User, error)
+	UsersByIDs(ctx context.Context, ids []int) ([]models.User, error)
+	Create(ctx context.Context, user models.User) (int, error)
+	Update(ctx context.Context, user models.UpdateUserInput, id int) error
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) error
+	Erase(ctx context.Context, id int) (models.User, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type TaskProvider interface {
-	Create(task models.InputTaskCreate) (int, error)
-	Update(task models.InputTaskUpdate) error
-	Delete(task models.InputTaskDelete) error
-	Tasks(task models.InputTask) ([]models.OutputTask, error)
+	Create(ctx context.Context, task models.InputTaskCreate) (int, error)
+	Update(ctx context.Context, task models.InputTaskUpdate) error
+	Patch(ctx context.Context, taskID int, input models.InputTaskPatch) error
+	Delete(ctx context.Context, task models.InputTaskDelete) error
+	Tasks(ctx context.Context, task models.InputTask) (models.TaskPage, error)
+	Restore(ctx context.Context, taskID int) error
+	TaskById(ctx context.Context, taskID int) (models.Task, error)
+	TasksByUser(ctx context.Context, userID int) ([]models.Task, error)
+	TasksByUsers(ctx context.Context, userIDs []int, period models.TaskPeriod) ([]models.Task, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	CountRunning(ctx context.Context) (int, error)
+}
+
+// AuditProvider keeps the append-only log of changes.
+type AuditProvider interface {
+	Record(ctx context.Context, event models.AuditEvent) error
+	Events(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error)
+	Redact(ctx context.Context, entity string, entityID int, fields []string) error
+}
+
+// IdempotencyProvider keeps the responses to requests made with an
+// Idempotency-Key, so that their retries are answered without running them again.
+type IdempotencyProvider interface {
+	Reserve(ctx context.Context, key models.IdempotencyKey, expiredBefore, abandonedBefore time.Time) (models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key models.IdempotencyKey) error
+	Release(ctx context.Context, key models.IdempotencyKey) error
+	PurgeUser(ctx context.Context, userID int) error
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// WebhookProvider keeps the webhooks and the outbox of their deliveries.
+type WebhookProvider interface {
+	Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+	Webhooks(ctx context.Context) ([]models.Webhook, error)
+	WebhookByID(ctx context.Context, id int) (models.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	Enqueue(ctx context.Context, event models.Event) error
+	Claim(ctx context.Context, limit int, lockFor time.Duration) ([]models.OutgoingWebhook, error)
+	Finish(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error
+	Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (models.WebhookDeliveryPage, error)
+	Redeliver(ctx context.Context, webhookID int, deliveryID int64) (models.WebhookDelivery, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OutboxProvider keeps the events of the changes until the relay has handed
+// them to every sink.
+type OutboxProvider interface {
+	Add(ctx context.Context, event models.Event) error
+	Claim(ctx context.Context, sink, owner string, limit int, lease time.Duration) ([]models.Event, error)
+	Published(ctx context.Context, sink, owner string, ids []int64, sinks []string) error
+	Settle(ctx context.Context, sinks []string) (int64, error)
+	Last(ctx context.Context) (int64, error)
+	Tail(ctx context.Context, after int64, missing []int64, limit int) ([]models.Event, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 type Storage struct {
 	UserProvider
 	TaskProvider
+	AuditProvider
+	IdempotencyProvider
+	WebhookProvider
+	OutboxProvider
+	Transactor
 }
 
 func NewStorage(db *sqlx.DB) *Storage {
 	return &Storage{
-		UserProvider: NewUserStorage(db),
-		TaskProvider: NewTaskStorage(db),
+		UserProvider:        NewUserStorage(db),
+		TaskProvider:        NewTaskStorage(db),
+		AuditProvider:       NewAuditStorage(db),
+		IdempotencyProvider: NewIdempotencyStorage(db),
+		WebhookProvider:     NewWebhookStorage(db),
+		OutboxProvider:      NewOutboxStorage(db),
+		Transactor:          NewTxManager(db),
 	}
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -9,8 +10,39 @@ import (
 
 	"github.com/3XBAT/time-tracker/internal/domain/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+const taskDuration = "EXTRACT(EPOCH FROM (end_time - start_time))::float8"
+
+var taskSortFields = map[string]sortField{
+	"name":       {expr: "name", value: cursorString},
+	"start_time": {expr: "start_time", value: cursorTime},
+	"duration":   {expr: taskDuration, value: cursorNumber},
+}
+
+// taskRow is a finished task as it is read for listings.
+type taskRow struct {
+	Id        int       `db:"id"`
+	Name      string    `db:"name"`
+	StartTime time.Time `db:"start_time"`
+	EndTime   time.Time `db:"end_time"`
+	Duration  float64   `db:"duration"`
+	Version   int       `db:"version"`
+}
+
+// sortValue is the cursor value of the row for the sort field, times are
+// kept with full precision so that the keyset comparison is exact.
+func (r taskRow) sortValue(field string) any {
+	switch field {
+	case "name":
+		return r.Name
+	case "start_time":
+		return r.StartTime.Format(time.RFC3339Nano)
+	}
+	return r.Duration
+}
+
 type TaskStorage struct {
 	db *sqlx.DB
 }
@@ -19,29 +51,38 @@ func NewTaskStorage(db *sqlx.DB) *TaskStorage {
 	return &TaskStorage{db: db}
 }
 
-func (s *TaskStorage) Create(input models.InputTaskCreate) (int, error) {
+func (s *TaskStorage) Create(ctx context.Context, input models.InputTaskCreate) (int, error) {
 	const op = "storage.task.Create"
 
 	if input.StartPeriod == nil {
 		now := time.Now()
 		input.StartPeriod = &now
 	}
+	// the insert checks the user itself and locks it against a concurrent
+	// delete, which would otherwise miss the new task
 	query := fmt.Sprintf(`INSERT INTO tasks (user_id, name, start_time)
-VALUES ($1, $2, $3) RETURNING id`)
+SELECT $1, $2, $3 FROM users WHERE id = $1 AND deleted_at IS NULL FOR SHARE RETURNING id`)
 
 	var id int
 
-	err := s.db.QueryRow(query, input.UserID, input.Name, input.StartPeriod).Scan(&id)
+	err := conn(ctx, s.db).QueryRowxContext(ctx, query, input.UserID, input.Name, input.StartPeriod).Scan(&id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 	return id, nil
 }
 
-func (s *TaskStorage) Update(input models.InputTaskUpdate) error {
+func (s *TaskStorage) Update(ctx context.Context, input models.InputTaskUpdate) error {
 	const op = "storage.task.Update"
 
-	res, err := s.IsUpdate(input.Id)
+	if _, err := s.TaskById(ctx, input.Id); err != nil {
+		return err
+	}
+
+	res, err := s.IsUpdate(ctx, input.Id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -49,34 +90,100 @@ func (s *TaskStorage) Update(input models.InputTaskUpdate) error {
 		return ErrTaskEnded
 	}
 
-	query := fmt.Sprintf(`UPDATE tasks SET end_time = $1 WHERE id = $2 AND user_id = $3 AND end_time IS NULL`)
+	query := fmt.Sprintf(`UPDATE tasks SET end_time = $1, version = version + 1
+		WHERE id = $2 AND ($3 = 0 OR user_id = $3) AND end_time IS NULL AND deleted_at IS NULL AND ($4 = 0 OR version = $4)`)
 
 	endTime := time.Now()
 
-	_, err = s.db.Exec(query, endTime, input.Id, input.UserID)
+	updated, err := conn(ctx, s.db).ExecContext(ctx, query, endTime, input.Id, input.UserID, input.Version)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	res, err = s.IsUpdate(input.Id)
-	if err != nil {
+	if err = s.checkAffected(ctx, updated, input.Id, input.UserID); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if !res {
-		return ErrBadRequest
+	return nil
+}
+
+// Patch changes the given fields and bumps the version of the task, the
+// version is checked as in Update. The task is locked while the period it
+// ends up with is checked, the end time of a running task is left to Update.
+func (s *TaskStorage) Patch(ctx context.Context, taskID int, input models.InputTaskPatch) error {
+	const op = "storage.task.Patch"
+
+	if input.Name == nil && input.StartTime == nil && input.EndTime == nil {
+		return fmt.Errorf("%s: %w", op, ErrNothingToUpdate)
+	}
+
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		var task models.Task
+		query := fmt.Sprintf(`SELECT * FROM tasks WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`)
+		if err := sqlx.GetContext(ctx, conn(ctx, s.db), &task, query, taskID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTaskNotFound
+			}
+			return err
+		}
+
+		if input.Version != 0 && task.Version != input.Version {
+			return ErrVersionMismatch
+		}
+		if input.EndTime != nil && task.EndTime == nil {
+			return ErrTaskRunning
+		}
+
+		if input.Name != nil {
+			task.Name = *input.Name
+		}
+		if input.StartTime != nil {
+			task.StartTime = *input.StartTime
+		}
+		if input.EndTime != nil {
+			task.EndTime = input.EndTime
+		}
+		if task.EndTime != nil && task.EndTime.Before(task.StartTime) {
+			return ErrInvalidPeriod
+		}
+
+		query = fmt.Sprintf(`UPDATE tasks SET name = $2, start_time = $3, end_time = $4, version = version + 1 WHERE id = $1`)
+		_, err := conn(ctx, s.db).ExecContext(ctx, query, taskID, task.Name, task.StartTime, task.EndTime)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
-func (s *TaskStorage) IsUpdate(taskID int) (bool, error) {
+// checkAffected tells why a conditional change of the task matched no rows:
+// it is gone, belongs to another user, has been finished or its version has
+// changed in the meantime.
+func (s *TaskStorage) checkAffected(ctx context.Context, res sql.Result, taskID, userID int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	task, err := s.TaskById(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if userID != 0 && task.UserID != userID {
+		return ErrBadRequest
+	}
+	return ErrVersionMismatch
+}
+
+func (s *TaskStorage) IsUpdate(ctx context.Context, taskID int) (bool, error) {
 	const op = "storage.task.IsUpdate"
 
 	query := fmt.Sprintf(`SELECT end_time FROM tasks WHERE id = $1`)
 	var endTime sql.NullTime
 
-	err := s.db.QueryRow(query, taskID).Scan(&endTime)
+	err := conn(ctx, s.db).QueryRowxContext(ctx, query, taskID).Scan(&endTime)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
@@ -87,58 +194,146 @@ func (s *TaskStorage) IsUpdate(taskID int) (bool, error) {
 	return endTime.Valid, nil
 }
 
-func (s *TaskStorage) Delete(input models.InputTaskDelete) error {
+func (s *TaskStorage) Delete(ctx context.Context, input models.InputTaskDelete) error {
 	const op = "storage.task.Delete"
 
-	_, err := s.TaskById(input.TaskID)
+	_, err := s.TaskById(ctx, input.TaskID)
 	if err != nil {
 		return err
 	}
 
-	query := fmt.Sprintf(`DELETE FROM tasks WHERE id = $1 AND user_id = $2`)
-	_, err = s.db.Exec(query, input.TaskID, input.UserID)
+	query := fmt.Sprintf(`UPDATE tasks SET deleted_at = now(), version = version + 1
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND deleted_at IS NULL AND ($3 = 0 OR version = $3)`)
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, input.TaskID, input.UserID, input.Version)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err = s.checkAffected(ctx, res, input.TaskID, input.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func (s *TaskStorage) Tasks(input models.InputTask) ([]models.OutputTask, error) {
+func (s *TaskStorage) Tasks(ctx context.Context, input models.InputTask) (models.TaskPage, error) {
 	const op = "storage.task.Tasks"
-	var tasks []models.OutputTask
+	var page models.TaskPage
 
-	query, args := buildQueryForTasks(input)
+	order, err := parseSort(input.Sort, taskSortFields, sortOrder{field: "duration", desc: true})
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+	after, err := decodeCursor(input.Cursor, order)
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	where, args := buildQueryForTasks(input)
 
-	rows, err := s.db.Query(query, args...)
+	if err = sqlx.GetContext(ctx, conn(ctx, s.db), &page.Total, "SELECT COUNT(*) FROM tasks"+where, args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT id, name, start_time, end_time, version, ` + taskDuration + ` AS duration FROM tasks` + where
+	expr := taskSortFields[order.field].expr
+	if after != nil {
+		cond, _ := order.keyset(expr, len(args)+1, len(args)+2)
+		query += " AND " + cond
+		args = append(args, after.Value, after.ID)
+	}
+	_, orderBy := order.keyset(expr, 0, 0)
+	query += orderBy + fmt.Sprintf(" LIMIT $%d", len(args)+1)
+	// one extra row tells whether there is a next page
+	args = append(args, input.Limit+1)
+
+	var rows []taskRow
+	if err = sqlx.SelectContext(ctx, conn(ctx, s.db), &rows, query, args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(rows) > input.Limit {
+		rows = rows[:input.Limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:  order.String(),
+			Value: last.sortValue(order.field),
+			ID:    last.Id,
+		})
+	}
+
+	page.Tasks = make([]models.OutputTask, 0, len(rows))
+	for _, row := range rows {
+		page.Tasks = append(page.Tasks, models.OutputTask{
+			Id:        row.Id,
+			Name:      row.Name,
+			StartTime: row.StartTime,
+			EndTime:   row.EndTime,
+			Duration:  formatDuration(time.Duration(row.Duration) * time.Second),
+			Version:   row.Version,
+		})
+	}
+
+	return page, nil
+}
+
+// Restore brings back a deleted task, tasks of deleted users are restored
+// only together with their owner.
+func (s *TaskStorage) Restore(ctx context.Context, taskID int) error {
+	const op = "storage.task.Restore"
+
+	query := fmt.Sprintf(`UPDATE tasks t SET deleted_at = NULL, version = t.version + 1 FROM users u
+		WHERE t.id = $1 AND t.deleted_at IS NOT NULL AND u.id = t.user_id AND u.deleted_at IS NULL`)
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, taskID)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var task models.OutputTask
-		var durationSeconds float64
-		if err := rows.Scan(&task.Name, &durationSeconds); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
-		}
-		task.Duration = formatDuration(time.Duration(durationSeconds) * time.Second)
-		tasks = append(tasks, task)
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed during rows iteration: %w", err)
+	if n == 0 {
+		return ErrTaskNotFound
 	}
 
-	return tasks, nil
+	return nil
+}
+
+// Purge permanently removes the tasks deleted before the given time and
+// returns their number.
+func (s *TaskStorage) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.task.Purge"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, `DELETE FROM tasks WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.RowsAffected()
+}
+
+// CountRunning returns the number of started tasks that have not been finished.
+func (s *TaskStorage) CountRunning(ctx context.Context) (int, error) {
+	const op = "storage.task.CountRunning"
+
+	var count int
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &count, `SELECT COUNT(*) FROM tasks WHERE start_time IS NOT NULL AND end_time IS NULL AND deleted_at IS NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
 }
 
-func (s *TaskStorage) TaskById(taskID int) (models.Task, error) {
+func (s *TaskStorage) TaskById(ctx context.Context, taskID int) (models.Task, error) {
 	const op = "storage.task.TaskById"
 	var task models.Task
 
-	query := fmt.Sprintf(`SELECT * FROM tasks WHERE id = $1`)
+	query := fmt.Sprintf(`SELECT * FROM tasks WHERE id = $1 AND deleted_at IS NULL`)
 
-	err := s.db.Get(&task, query, taskID)
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &task, query, taskID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return task, ErrTaskNotFound
@@ -150,26 +345,60 @@ func (s *TaskStorage) TaskById(taskID int) (models.Task, error) {
 
 }
 
+// TasksByUser returns every task of the user including the deleted ones.
+func (s *TaskStorage) TasksByUser(ctx context.Context, userID int) ([]models.Task, error) {
+	const op = "storage.task.TasksByUser"
+	tasks := []models.Task{}
+
+	query := fmt.Sprintf(`SELECT * FROM tasks WHERE user_id = $1 ORDER BY id`)
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &tasks, query, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tasks, nil
+}
+
+// TasksByUsers returns the tasks of the given users, running ones included,
+// started within the period, ordered by user and start time.
+func (s *TaskStorage) TasksByUsers(ctx context.Context, userIDs []int, period models.TaskPeriod) ([]models.Task, error) {
+	const op = "storage.task.TasksByUsers"
+	tasks := []models.Task{}
+
+	query := `SELECT * FROM tasks WHERE user_id = ANY($1) AND deleted_at IS NULL`
+	args := []interface{}{pq.Array(userIDs)}
+	if period.From != nil {
+		args = append(args, *period.From)
+		query += fmt.Sprintf(" AND start_time >= $%d", len(args))
+	}
+	if period.To != nil {
+		args = append(args, *period.To)
+		query += fmt.Sprintf(" AND start_time < $%d", len(args))
+	}
+	query += " ORDER BY user_id, start_time, id"
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &tasks, query, args...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tasks, nil
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
 	minutes := int(d.Minutes()) % 60
-	
+
 	if days > 0 {
 		return fmt.Sprintf("%dd %02dh %02dm", days, hours, minutes)
 	}
 	return fmt.Sprintf("%02dh %02dm", hours, minutes)
 }
 
+// buildQueryForTasks returns the WHERE clause selecting the finished
+// tasks of the user in the requested period.
 func buildQueryForTasks(input models.InputTask) (string, []interface{}) {
-	baseQuery := `SELECT 
-            name, 
-            EXTRACT(EPOCH FROM (end_time - start_time)) AS duration
-        FROM 
-            tasks
-        WHERE user_id = $1 AND 
-            start_time IS NOT NULL AND 
-            end_time IS NOT NULL `
+	where := ` WHERE user_id = $1 AND start_time IS NOT NULL AND end_time IS NOT NULL AND deleted_at IS NULL`
 	var conditions []string
 	var args []interface{}
 	args = append(args, input.UserID)
@@ -184,11 +413,8 @@ func buildQueryForTasks(input models.InputTask) (string, []interface{}) {
 	}
 
 	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
+		where += " AND " + strings.Join(conditions, " AND ")
 	}
 
-	baseQuery += ` ORDER BY 
-            duration DESC;`
-
-	return baseQuery, args
+	return where, args
 }
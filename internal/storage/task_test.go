@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func TestTaskCreateRequiresLiveUser(t *testing.T) {
+	ctx := context.Background()
+	db := storagetest.DB(t, "users", "tasks")
+	users, tasks := NewUserStorage(db), NewTaskStorage(db)
+
+	userID, err := users.Create(ctx, models.User{PassportNumber: "1234 567890", Name: "Иван", Surname: "Иванов"})
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+
+	if _, err = tasks.Create(ctx, models.InputTaskCreate{UserID: userID, Name: "first"}); err != nil {
+		t.Fatalf("Create task: %v", err)
+	}
+
+	if err = users.Delete(ctx, userID, 0); err != nil {
+		t.Fatalf("Delete user: %v", err)
+	}
+	if _, err = tasks.Create(ctx, models.InputTaskCreate{UserID: userID, Name: "second"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Create task of a deleted user = %v, want ErrUserNotFound", err)
+	}
+	if _, err = tasks.Create(ctx, models.InputTaskCreate{UserID: userID + 1, Name: "third"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Create task of an unknown user = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestTaskPatch(t *testing.T) {
+	ctx := context.Background()
+	db := storagetest.DB(t, "users", "tasks")
+	users, tasks := NewUserStorage(db), NewTaskStorage(db)
+
+	userID, err := users.Create(ctx, models.User{PassportNumber: "1234 567890", Name: "Иван", Surname: "Иванов"})
+	if err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	taskID, err := tasks.Create(ctx, models.InputTaskCreate{UserID: userID, Name: "review", StartPeriod: &start})
+	if err != nil {
+		t.Fatalf("Create task: %v", err)
+	}
+
+	end := start.Add(time.Hour)
+	if err = tasks.Patch(ctx, taskID, models.InputTaskPatch{EndTime: &end}); !errors.Is(err, ErrTaskRunning) {
+		t.Fatalf("Patch end_time of a running task = %v, want ErrTaskRunning", err)
+	}
+	if err = tasks.Update(ctx, models.InputTaskUpdate{Id: taskID}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	name := "code review"
+	if err = tasks.Patch(ctx, taskID, models.InputTaskPatch{Name: &name, EndTime: &end, Version: 2}); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	task, err := tasks.TaskById(ctx, taskID)
+	if err != nil {
+		t.Fatalf("TaskById: %v", err)
+	}
+	if task.Name != name || !task.StartTime.Equal(start) || task.EndTime == nil || !task.EndTime.Equal(end) || task.Version != 3 {
+		t.Fatalf("patched task %+v", task)
+	}
+
+	tests := []struct {
+		patch models.InputTaskPatch
+		want  error
+	}{
+		{patch: models.InputTaskPatch{}, want: ErrNothingToUpdate},
+		{patch: models.InputTaskPatch{Name: &name, Version: 2}, want: ErrVersionMismatch},
+		{patch: models.InputTaskPatch{StartTime: ptr(end.Add(time.Minute))}, want: ErrInvalidPeriod},
+		{patch: models.InputTaskPatch{EndTime: ptr(start.Add(-time.Minute))}, want: ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		if err = tasks.Patch(ctx, taskID, tt.patch); !errors.Is(err, tt.want) {
+			t.Errorf("Patch(%+v) = %v, want %v", tt.patch, err, tt.want)
+		}
+	}
+	if err = tasks.Patch(ctx, taskID+1, models.InputTaskPatch{Name: &name}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Patch of an unknown task = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
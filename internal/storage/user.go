@@ -1,22 +1,32 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/3XBAT/time-tracker/internal/domain/models"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"strings"
+	"time"
 )
 
 const (
 	ID             = "id"
 	Name           = "name"
 	Surname        = "surname"
+	Patronymic     = "patronymic"
 	PassportNumber = "passport_number"
-	Address        = "address"
+	Address        = "addr"
 )
 
+var userSortFields = map[string]sortField{
+	ID:      {expr: ID, value: cursorNumber},
+	Name:    {expr: Name, value: cursorString},
+	Surname: {expr: Surname, value: cursorString},
+}
+
 type UserStorage struct {
 	db *sqlx.DB
 }
@@ -25,29 +35,69 @@ func NewUserStorage(db *sqlx.DB) *UserStorage {
 	return &UserStorage{db: db}
 }
 
-func (s *UserStorage) Users(params models.QueryParams) ([]models.User, error) {
+func (s *UserStorage) Users(ctx context.Context, params models.QueryParams) (models.UserPage, error) {
 	const op = "storage.Users"
-	var users []models.User
+	var page models.UserPage
 
-	query, args := buildQuery(params)
+	order, err := parseSort(params.Sort, userSortFields, sortOrder{field: ID})
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+	after, err := decodeCursor(params.Cursor, order)
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
 
-	err := s.db.Select(&users, query, args...)
+	filters, err := buildQuery(params)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrUserNotFound
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = sqlx.GetContext(ctx, conn(ctx, s.db), &page.Total, "SELECT COUNT(*) FROM users"+filters.where(), filters.args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	expr := userSortFields[order.field].expr
+	if after != nil {
+		value := after.Value
+		if order.field == ID {
+			value = after.ID
 		}
-		return nil, fmt.Errorf("%s: %w", op, err)
+		cond, _ := order.keyset(expr, len(filters.args)+1, len(filters.args)+2)
+		filters.conditions = append(filters.conditions, cond)
+		filters.args = append(filters.args, value, after.ID)
 	}
-	return users, nil
+	_, orderBy := order.keyset(expr, 0, 0)
+	// one extra row tells whether there is a next page
+	query := "SELECT * FROM users" + filters.where() + orderBy + " LIMIT " + filters.arg(params.Limit+1)
+
+	if err = sqlx.SelectContext(ctx, conn(ctx, s.db), &page.Users, query, filters.args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(page.Users) > params.Limit {
+		page.Users = page.Users[:params.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeCursor(cursor{
+			Sort:  order.String(),
+			Value: userSortValue(last, order.field),
+			ID:    last.ID,
+		})
+	}
+	if page.Users == nil {
+		page.Users = []models.User{}
+	}
+
+	return page, nil
 }
 
-func (s *UserStorage) UserByID(id int) (models.User, error) {
+func (s *UserStorage) UserByID(ctx context.Context, id int) (models.User, error) {
 	const op = "storage.UserByID"
 	var user models.User
 
-	query := fmt.Sprintf("SELECT * FROM users WHERE id = $1")
+	query := fmt.Sprintf("SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL")
 
-	err := s.db.Get(&user, query, id)
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &user, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
@@ -59,15 +109,30 @@ func (s *UserStorage) UserByID(id int) (models.User, error) {
 	return user, nil
 }
 
-func (s *UserStorage) Create(user models.User) (int, error) {
+// UsersByIDs returns the users with the given ids that exist, in no
+// particular order.
+func (s *UserStorage) UsersByIDs(ctx context.Context, ids []int) ([]models.User, error) {
+	const op = "storage.UsersByIDs"
+	users := []models.User{}
+
+	query := `SELECT * FROM users WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &users, query, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (s *UserStorage) Create(ctx context.Context, user models.User) (int, error) {
 	const op = "storage.CreateUser"
 
 	var id int
 
-	query := fmt.Sprintf(`INSERT INTO users (name, surname, patronymic, passport_number, address)
+	query := fmt.Sprintf(`INSERT INTO users (name, surname, patronymic, passport_number, addr)
 		 VALUES ($1, $2, $3, $4, $5) RETURNING id`)
 
-	row := s.db.QueryRow(query, user.Name, user.Surname, user.Patronymic, user.PassportNumber, user.Address)
+	row := conn(ctx, s.db).QueryRowxContext(ctx, query, user.Name, user.Surname, user.Patronymic, user.PassportNumber, user.Address)
 	if err := row.Scan(&id); err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
@@ -75,104 +140,216 @@ func (s *UserStorage) Create(user models.User) (int, error) {
 	return id, nil
 }
 
-func (s *UserStorage) Update(input models.UpdateUserInput, id int) error {
+// Update changes the given fields and bumps the version of the user. When
+// input.Version is set the user must still have it, otherwise
+// ErrVersionMismatch is returned.
+func (s *UserStorage) Update(ctx context.Context, input models.UpdateUserInput, id int) error {
 	const op = "storage.UpdateUser"
 
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 
-	if input.Address != nil {
-		setValues = append(setValues, fmt.Sprintf("addr=$%d", len(setValues)+1))
-		args = append(args, *input.Address)
+	for _, f := range []struct {
+		column string
+		value  *string
+	}{
+		{PassportNumber, input.PassportNumber},
+		{Name, input.Name},
+		{Surname, input.Surname},
+		{Patronymic, input.Patronymic},
+		{Address, input.Address},
+	} {
+		if f.value == nil {
+			continue
+		}
+		args = append(args, *f.value)
+		setValues = append(setValues, fmt.Sprintf("%s=$%d", f.column, len(args)))
 	}
 
-	if input.PassportNumber != nil {
-		setValues = append(setValues, fmt.Sprintf("passport_number=$%d", len(setValues)+1))
-		args = append(args, *input.PassportNumber)
+	if len(setValues) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNothingToUpdate)
 	}
 
-	setQuery := strings.Join(setValues, ", ")
-	fmt.Println(setQuery)
-	query := fmt.Sprintf(`UPDATE users SET %s WHERE id = $%d`, setQuery, len(setValues)+1)
+	setValues = append(setValues, "version=version+1")
 	args = append(args, id)
+	query := fmt.Sprintf(`UPDATE users SET %s WHERE id = $%d AND deleted_at IS NULL`, strings.Join(setValues, ", "), len(args))
+	if input.Version != 0 {
+		args = append(args, input.Version)
+		query += fmt.Sprintf(" AND version = $%d", len(args))
+	}
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.checkAffected(ctx, res, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	fmt.Println(query, args)
+	return nil
+}
 
-	_, err := s.db.Exec(query, args...)
+// checkAffected tells why a conditional update of the user matched no rows:
+// either the user is gone or their version has changed in the meantime.
+func (s *UserStorage) checkAffected(ctx context.Context, res sql.Result, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
 
-	return err
+	if _, err = s.UserByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
 }
 
-func (s *UserStorage) Delete(userID int) error {
+// Delete marks the user and their tasks as deleted. The tasks get the same
+// deletion time as the user, so that Restore brings back exactly them.
+// The version check is the same as in Update.
+func (s *UserStorage) Delete(ctx context.Context, userID, version int) error {
 	const op = "storage.Delete"
 
-	tx, err := s.db.Begin()
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		var deletedAt time.Time
+		deleteUserQuery := fmt.Sprintf(`UPDATE users SET deleted_at = now(), version = version + 1
+			WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2) RETURNING deleted_at`)
+		if err := conn(ctx, s.db).QueryRowxContext(ctx, deleteUserQuery, userID, version).Scan(&deletedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				if _, err = s.UserByID(ctx, userID); err != nil {
+					return err
+				}
+				return ErrVersionMismatch
+			}
+			return err
+		}
+
+		deleteTasksQuery := fmt.Sprintf(`UPDATE tasks SET deleted_at = $2 WHERE user_id = $1 AND deleted_at IS NULL`)
+		_, err := conn(ctx, s.db).ExecContext(ctx, deleteTasksQuery, userID, deletedAt)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	deleteTasksQuery := fmt.Sprintf(`DELETE FROM tasks WHERE user_id = $1`)
-	_, err = tx.Exec(deleteTasksQuery, userID)
+	return nil
+}
+
+// Restore brings back a deleted user along with the tasks deleted together
+// with them. Tasks deleted on their own before stay deleted.
+func (s *UserStorage) Restore(ctx context.Context, userID int) error {
+	const op = "storage.Restore"
+
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		var deletedAt time.Time
+		deletedAtQuery := fmt.Sprintf(`SELECT deleted_at FROM users WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`)
+		if err := conn(ctx, s.db).QueryRowxContext(ctx, deletedAtQuery, userID).Scan(&deletedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		restoreUserQuery := fmt.Sprintf(`UPDATE users SET deleted_at = NULL, version = version + 1 WHERE id = $1`)
+		if _, err := conn(ctx, s.db).ExecContext(ctx, restoreUserQuery, userID); err != nil {
+			return err
+		}
+
+		restoreTasksQuery := fmt.Sprintf(`UPDATE tasks SET deleted_at = NULL WHERE user_id = $1 AND deleted_at = $2`)
+		_, err := conn(ctx, s.db).ExecContext(ctx, restoreTasksQuery, userID, deletedAt)
+		return err
+	})
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	deleteUserQuery := fmt.Sprintf(`DELETE FROM users WHERE id = $1`)
-	_, err = tx.Exec(deleteUserQuery, userID)
+	return nil
+}
+
+// Purge permanently removes the users deleted before the given time together
+// with all their tasks and returns the number of removed users.
+func (s *UserStorage) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.Purge"
+
+	var purged int64
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		purgeTasksQuery := fmt.Sprintf(`DELETE FROM tasks WHERE user_id IN (SELECT id FROM users WHERE deleted_at < $1)`)
+		if _, err := conn(ctx, s.db).ExecContext(ctx, purgeTasksQuery, before); err != nil {
+			return err
+		}
+
+		purgeUsersQuery := fmt.Sprintf(`DELETE FROM users WHERE deleted_at < $1`)
+		res, err := conn(ctx, s.db).ExecContext(ctx, purgeUsersQuery, before)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return tx.Commit()
+	return purged, nil
 }
 
-func buildQuery(params models.QueryParams) (string, []interface{}) {
-	baseQuery := "SELECT * FROM users WHERE 1=1"
-	var args []interface{}
-	var conditions []string
+// Erase replaces the personal data of a user, deleted or not, with empty
+// values. The user and their tasks are kept for reporting.
+func (s *UserStorage) Erase(ctx context.Context, userID int) (models.User, error) {
+	const op = "storage.Erase"
+	var user models.User
 
-	if params.ID != "" {
-		conditions, args = appendCondition(conditions, args, ID, params.ID)
-	}
-	if params.Name != "" {
-		conditions, args = appendCondition(conditions, args, Name, params.Name)
-	}
-	if params.PassportNumber != "" {
-		conditions, args = appendCondition(conditions, args, PassportNumber, params.PassportNumber)
-	}
-	if params.Address != "" {
-		conditions, args = appendCondition(conditions, args, Address, params.Address)
-	}
-	if params.Surname != "" {
-		conditions, args = appendCondition(conditions, args, Surname, params.Surname)
+	query := fmt.Sprintf(`UPDATE users
+		SET passport_number = '', name = '', surname = '', patronymic = '', addr = '',
+			erased_at = COALESCE(erased_at, now()), version = version + 1
+		WHERE id = $1 RETURNING *`)
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &user, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return user, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
+	return user, nil
+}
+
+// buildQuery collects the conditions for the filters and the search
+// query in params.
+func buildQuery(params models.QueryParams) (*filterBuilder, error) {
+	b := &filterBuilder{conditions: []string{"deleted_at IS NULL"}}
+
+	for _, f := range []struct {
+		field filterField
+		value string
+	}{
+		{filterField{column: ID, numeric: true}, params.ID},
+		{filterField{column: Name}, params.Name},
+		{filterField{column: Surname}, params.Surname},
+		{filterField{column: Patronymic}, params.Patronymic},
+		{filterField{column: PassportNumber}, params.PassportNumber},
+		{filterField{column: Address}, params.Address},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if err := b.add(f.field, f.value); err != nil {
+			return nil, err
+		}
 	}
 
-	baseQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
-	args = append(args, params.Limit, params.Offset)
+	b.search(params.Search)
 
-	return baseQuery, args
+	return b, nil
 }
 
-func appendCondition(conditions []string, args []interface{}, field, value string) ([]string, []interface{}) {
-	if strings.HasPrefix(value, ">=") || strings.HasPrefix(value, "<=") || strings.HasPrefix(value, ">") || strings.HasPrefix(value, "<") {
-		operator := value[:2]
-		if operator[1] != '=' {
-			operator = value[:1]
-			conditions = append(conditions, fmt.Sprintf("%s %s $%d", field, operator, len(args)+1))
-			args = append(args, value[1:])
-		} else {
-			conditions = append(conditions, fmt.Sprintf("%s %s $%d", field, operator, len(args)+1))
-			args = append(args, value[2:])
-		}
-	} else {
-		conditions = append(conditions, fmt.Sprintf("%s = $%d", field, len(args)+1))
-		args = append(args, value)
+func userSortValue(user models.User, field string) any {
+	switch field {
+	case Name:
+		return user.Name
+	case Surname:
+		return user.Surname
 	}
-	return conditions, args
+	return user.ID
 }
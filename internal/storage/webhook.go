@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	webhookColumns  = `id, url, event_types, secret, created_at`
+	deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at, delivered_at`
+)
+
+type webhookRow struct {
+	ID         int            `db:"id"`
+	URL        string         `db:"url"`
+	EventTypes pq.StringArray `db:"event_types"`
+	Secret     string         `db:"secret"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+func (r webhookRow) model() models.Webhook {
+	return models.Webhook{
+		ID:         r.ID,
+		URL:        r.URL,
+		EventTypes: r.EventTypes,
+		Secret:     r.Secret,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+type WebhookStorage struct {
+	db *sqlx.DB
+}
+
+func NewWebhookStorage(db *sqlx.DB) *WebhookStorage {
+	return &WebhookStorage{db: db}
+}
+
+func (s *WebhookStorage) Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	const op = "storage.webhook.Create"
+	var row webhookRow
+
+	query := `INSERT INTO webhooks (url, event_types, secret) VALUES ($1, $2, $3) RETURNING ` + webhookColumns
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &row, query, webhook.URL, pq.Array(webhook.EventTypes), webhook.Secret)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return row.model(), nil
+}
+
+// Webhooks returns every webhook, the secrets left out.
+func (s *WebhookStorage) Webhooks(ctx context.Context) ([]models.Webhook, error) {
+	const op = "storage.webhook.Webhooks"
+	var rows []webhookRow
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &rows, query); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	webhooks := make([]models.Webhook, 0, len(rows))
+	for _, row := range rows {
+		w := row.model()
+		w.Secret = ""
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, nil
+}
+
+// WebhookByID returns the webhook without its secret.
+func (s *WebhookStorage) WebhookByID(ctx context.Context, id int) (models.Webhook, error) {
+	const op = "storage.webhook.WebhookByID"
+	var row webhookRow
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &row, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w := row.model()
+	w.Secret = ""
+	return w, nil
+}
+
+// Delete removes the webhook along with its deliveries.
+func (s *WebhookStorage) Delete(ctx context.Context, id int) error {
+	const op = "storage.webhook.Delete"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// Enqueue queues the event for every webhook subscribed to its type,
+// within the transaction relaying it from the outbox when ctx carries one.
+func (s *WebhookStorage) Enqueue(ctx context.Context, event models.Event) error {
+	const op = "storage.webhook.Enqueue"
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT id, $1, $2 FROM webhooks WHERE $1 = ANY(event_types)`
+
+	if _, err = conn(ctx, s.db).ExecContext(ctx, query, event.Type, string(payload)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Claim takes up to limit pending deliveries that are due and counts the
+// attempt about to be made. They are hidden from the other claims for
+// lockFor, after which a delivery whose attempt was not finished, e.g.
+// because the instance sending it crashed, is due again.
+func (s *WebhookStorage) Claim(ctx context.Context, limit int, lockFor time.Duration) ([]models.OutgoingWebhook, error) {
+	const op = "storage.webhook.Claim"
+	var claimed []models.OutgoingWebhook
+
+	query := `WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d
+			SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+			FROM due WHERE d.id = due.id
+			RETURNING d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.created_at, d.delivered_at
+		)
+		SELECT c.*, w.url, w.secret FROM claimed c JOIN webhooks w ON w.id = c.webhook_id ORDER BY c.id`
+
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &claimed, query, limit, lockFor.Seconds()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claimed, nil
+}
+
+// Finish logs the attempt and stores the outcome of a claimed delivery:
+// its status, next attempt and delivery time. The outcome is dropped when
+// the delivery was redelivered in the meantime.
+func (s *WebhookStorage) Finish(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error {
+	const op = "storage.webhook.Finish"
+
+	err := withinTx(ctx, s.db, func(ctx context.Context) error {
+		query := `INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, duration_ms, status_code, response_body, error)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+		_, err := conn(ctx, s.db).ExecContext(ctx, query,
+			delivery.ID, attempt.AttemptedAt, attempt.DurationMS, attempt.StatusCode, attempt.Response, attempt.Error,
+		)
+		if err != nil {
+			return err
+		}
+
+		query = `UPDATE webhook_deliveries SET status = $3, next_attempt_at = $4, delivered_at = $5
+			WHERE id = $1 AND attempts = $2 AND status = 'pending'`
+		_, err = conn(ctx, s.db).ExecContext(ctx, query,
+			delivery.ID, delivery.Attempts, delivery.Status, delivery.NextAttemptAt, delivery.DeliveredAt,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Deliveries returns a page of the deliveries of a webhook, newest first,
+// with their logs.
+func (s *WebhookStorage) Deliveries(ctx context.Context, filter models.WebhookDeliveryFilter) (models.WebhookDeliveryPage, error) {
+	const op = "storage.webhook.Deliveries"
+	var page models.WebhookDeliveryPage
+
+	order := sortOrder{field: ID, desc: true}
+	after, err := decodeCursor(filter.Cursor, order)
+	if err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b := &filterBuilder{}
+	b.conditions = append(b.conditions, "webhook_id = "+b.arg(filter.WebhookID))
+	if filter.Status != "" {
+		b.conditions = append(b.conditions, "status = "+b.arg(filter.Status))
+	}
+	if after != nil {
+		b.conditions = append(b.conditions, "id < "+b.arg(after.ID))
+	}
+
+	// one extra row tells whether there is a next page
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries" + b.where() +
+		" ORDER BY id DESC LIMIT " + b.arg(filter.Limit+1)
+
+	if err = sqlx.SelectContext(ctx, conn(ctx, s.db), &page.Deliveries, query, b.args...); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(page.Deliveries) > filter.Limit {
+		page.Deliveries = page.Deliveries[:filter.Limit]
+		last := page.Deliveries[len(page.Deliveries)-1]
+		page.NextCursor = encodeCursor(cursor{Sort: order.String(), ID: int(last.ID)})
+	}
+	if page.Deliveries == nil {
+		page.Deliveries = []models.WebhookDelivery{}
+	}
+
+	if err = s.attachLogs(ctx, page.Deliveries); err != nil {
+		return page, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+func (s *WebhookStorage) attachLogs(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	ids := make([]int64, len(deliveries))
+	byID := make(map[int64]int, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+		byID[d.ID] = i
+		deliveries[i].Log = []models.WebhookAttempt{}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var attempts []models.WebhookAttempt
+	query := `SELECT delivery_id, attempted_at, duration_ms, status_code, response_body, error
+		FROM webhook_delivery_attempts WHERE delivery_id = ANY($1) ORDER BY id`
+	if err := sqlx.SelectContext(ctx, conn(ctx, s.db), &attempts, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, a := range attempts {
+		d := &deliveries[byID[a.DeliveryID]]
+		d.Log = append(d.Log, a)
+	}
+	return nil
+}
+
+// Redeliver queues a delivery of the webhook to be sent again right away,
+// with a fresh round of attempts. Its log is kept.
+func (s *WebhookStorage) Redeliver(ctx context.Context, webhookID int, deliveryID int64) (models.WebhookDelivery, error) {
+	const op = "storage.webhook.Redeliver"
+	var delivery models.WebhookDelivery
+
+	query := `UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING ` + deliveryColumns
+
+	err := sqlx.GetContext(ctx, conn(ctx, s.db), &delivery, query, deliveryID, webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return delivery, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return delivery, fmt.Errorf("%s: %w", op, err)
+	}
+
+	redelivered := []models.WebhookDelivery{delivery}
+	if err = s.attachLogs(ctx, redelivered); err != nil {
+		return delivery, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redelivered[0], nil
+}
+
+// Purge removes the deliveries that were delivered or failed and were
+// queued before the given time, together with their logs.
+func (s *WebhookStorage) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const op = "storage.webhook.Purge"
+
+	res, err := conn(ctx, s.db).ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
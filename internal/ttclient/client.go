@@ -0,0 +1,144 @@
+// Package ttclient talks to the REST API for the terminal clients: it reads
+// their shared config and remembers the timer started from this machine.
+package ttclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// retries is how many times a request failing to reach the server is sent
+// again, changing requests carry an Idempotency-Key so that they are
+// applied only once.
+const retries = 2
+
+// Problem is the RFC 7807 body of the error responses.
+type Problem struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Errors []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (p *Problem) Error() string {
+	msg := p.Title
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	for _, f := range p.Errors {
+		msg += fmt.Sprintf("\n  %s %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// HasStatus tells whether err is a response with the given status.
+func HasStatus(err error, status int) bool {
+	var p *Problem
+	return errors.As(err, &p) && p.Status == status
+}
+
+type Client struct {
+	cfg     Config
+	baseURL string
+	http    *http.Client
+}
+
+func New(cfg Config) *Client {
+	return &Client{
+		cfg:     cfg,
+		baseURL: strings.TrimRight(cfg.Server, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// do sends a request to the API and decodes the response into out, unless
+// it is nil. Error responses are returned as *Problem.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, header http.Header, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var idempotencyKey string
+	if method != http.MethodGet {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		idempotencyKey = hex.EncodeToString(b)
+	}
+
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("Accept", "application/json")
+		if body != nil && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		if c.cfg.Actor != "" {
+			req.Header.Set("X-Actor", c.cfg.Actor)
+		}
+		if c.cfg.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+		}
+
+		resp, err = c.http.Do(req)
+		if err == nil {
+			break
+		}
+		if attempt == retries || ctx.Err() != nil {
+			return fmt.Errorf("failed to reach %s: %w", c.baseURL, err)
+		}
+		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		p := &Problem{Status: resp.StatusCode}
+		if err := json.Unmarshal(data, p); err != nil || p.Title == "" {
+			p.Title = http.StatusText(resp.StatusCode)
+		}
+		return p
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", c.baseURL, err)
+	}
+	return nil
+}
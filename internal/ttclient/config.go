@@ -0,0 +1,82 @@
+package ttclient
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"os"
+	"path/filepath"
+)
+
+const (
+	OutputHuman = "human"
+	OutputJSON  = "json"
+)
+
+// Config is shared by the terminal clients. It is read from the YAML file,
+// environment variables override it.
+type Config struct {
+	Server string `yaml:"server" env:"TT_SERVER" env-default:"http://localhost:8080"`
+	UserID int    `yaml:"user_id" env:"TT_USER_ID"`
+	// Actor is sent in the X-Actor header and attributes the changes in
+	// the audit log.
+	Actor string `yaml:"actor" env:"TT_ACTOR"`
+	// Token is sent as a bearer token, for servers behind an authenticating
+	// proxy.
+	Token  string `yaml:"token" env:"TT_TOKEN"`
+	Output string `yaml:"output" env:"TT_OUTPUT" env-default:"human"`
+}
+
+// ConfigDir holds the config file and the timer started from this machine.
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tt"), nil
+}
+
+// LoadConfig reads path, or config.yaml in the config directory when path
+// is empty. A missing default file is not an error.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	explicit := path != ""
+	if !explicit {
+		dir, err := ConfigDir()
+		if err != nil {
+			return cfg, err
+		}
+		path = filepath.Join(dir, "config.yaml")
+	}
+
+	var err error
+	if _, statErr := os.Stat(path); statErr == nil {
+		err = cleanenv.ReadConfig(path, &cfg)
+	} else if explicit {
+		err = statErr
+	} else {
+		err = cleanenv.ReadEnv(&cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate reports the first setting that prevents talking to the server.
+func (c Config) Validate() error {
+	if c.Server == "" {
+		return errors.New("server is not set, use -server, TT_SERVER or the config file")
+	}
+	if c.UserID <= 0 {
+		return errors.New("user is not set, use -user, TT_USER_ID or user_id in the config file")
+	}
+	switch c.Output {
+	case OutputHuman, OutputJSON:
+	default:
+		return fmt.Errorf("output must be %s or %s, got %q", OutputHuman, OutputJSON, c.Output)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package ttclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// pageSize is the largest page the API returns.
+const pageSize = 100
+
+// ErrTimerRunning is returned when a timer is started while another one is running.
+var ErrTimerRunning = errors.New("a timer is already running, stop it first")
+
+// ErrNoTimer is returned when there is no timer started from this machine.
+var ErrNoTimer = errors.New("no timer was started from this machine")
+
+func (c *Client) Task(ctx context.Context, id int) (models.Task, error) {
+	var task models.Task
+	err := c.do(ctx, http.MethodGet, taskPath(id), nil, nil, nil, &task)
+	return task, err
+}
+
+// Running returns the timer started from this machine if it is still
+// running, the saved timer is forgotten otherwise.
+func (c *Client) Running(ctx context.Context) (*models.Task, error) {
+	id, err := c.SavedTimer()
+	if err != nil || id == 0 {
+		return nil, err
+	}
+
+	task, err := c.Task(ctx, id)
+	if err != nil && !HasStatus(err, http.StatusNotFound) {
+		return nil, err
+	}
+	if err != nil || task.EndTime != nil {
+		return nil, c.ClearTimer()
+	}
+	return &task, nil
+}
+
+// Start creates a task starting now and remembers it as the running timer.
+func (c *Client) Start(ctx context.Context, name string) (models.Task, error) {
+	running, err := c.Running(ctx)
+	if err != nil {
+		return models.Task{}, err
+	}
+	if running != nil {
+		return models.Task{}, fmt.Errorf("#%d %q: %w", running.Id, running.Name, ErrTimerRunning)
+	}
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	input := models.InputTaskCreate{UserID: c.cfg.UserID, Name: name}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/tasks", nil, input, nil, &created); err != nil {
+		return models.Task{}, err
+	}
+	if err := c.saveTimer(created.ID); err != nil {
+		return models.Task{}, fmt.Errorf("timer started as #%d but could not be saved: %w", created.ID, err)
+	}
+
+	return c.Task(ctx, created.ID)
+}
+
+// Stop finishes the task, the running timer when id is zero.
+func (c *Client) Stop(ctx context.Context, id int) (models.Task, error) {
+	saved, err := c.SavedTimer()
+	if err != nil {
+		return models.Task{}, err
+	}
+	if id == 0 {
+		if saved == 0 {
+			return models.Task{}, ErrNoTimer
+		}
+		id = saved
+	}
+
+	var task models.Task
+	err = c.do(ctx, http.MethodPost, taskPath(id)+"/stop", nil, nil, anyVersion(), &task)
+	if saved == id {
+		// the timer is gone whether it has just been stopped, had been
+		// stopped elsewhere or deleted
+		if err == nil || HasStatus(err, http.StatusNotFound) || HasStatus(err, http.StatusConflict) {
+			if clearErr := c.ClearTimer(); clearErr != nil {
+				return task, clearErr
+			}
+		}
+	}
+	return task, err
+}
+
+// Delete deletes the task, it can be restored until purged.
+func (c *Client) Delete(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, taskPath(id), nil, nil, anyVersion(), nil)
+}
+
+// Edit changes the given fields of the task, provided it still has the
+// version the changes were made to.
+func (c *Client) Edit(ctx context.Context, id, version int, patch models.InputTaskPatch) (models.Task, error) {
+	header := http.Header{
+		"Content-Type": {"application/merge-patch+json"},
+		"If-Match":     {`"` + strconv.Itoa(version) + `"`},
+	}
+
+	var task models.Task
+	err := c.do(ctx, http.MethodPatch, taskPath(id), nil, patch, header, &task)
+	return task, err
+}
+
+func (c *Client) Restore(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodPost, taskPath(id)+"/restore", nil, nil, nil, nil)
+}
+
+// FinishedTasks reads every page of the finished tasks of the user that
+// started at or after from and ended before to, either may be nil.
+func (c *Client) FinishedTasks(ctx context.Context, from, to *time.Time) ([]models.OutputTask, error) {
+	query := url.Values{
+		"user_id": {strconv.Itoa(c.cfg.UserID)},
+		"limit":   {strconv.Itoa(pageSize)},
+		"sort":    {"start_time:asc"},
+	}
+	if from != nil {
+		query.Set("start_time", from.Format(time.RFC3339))
+	}
+	if to != nil {
+		query.Set("end_time", to.Format(time.RFC3339))
+	}
+
+	tasks := []models.OutputTask{}
+	for {
+		var page models.TaskPage
+		if err := c.do(ctx, http.MethodGet, "/api/v1/tasks", query, nil, nil, &page); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, page.Tasks...)
+
+		if page.NextCursor == "" {
+			return tasks, nil
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+func taskPath(id int) string {
+	return "/api/v1/tasks/" + strconv.Itoa(id)
+}
+
+// anyVersion lets the changes apply to whatever version the task has, the
+// terminal clients do not keep tasks around long enough to conflict.
+func anyVersion() http.Header {
+	return http.Header{"If-Match": {"*"}}
+}
+
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfWeek returns the start of the Monday of the week of t.
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
@@ -0,0 +1,78 @@
+package ttclient
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// timer is the task started from this machine. The API lists finished
+// tasks only, so the running one is remembered locally.
+type timer struct {
+	Server string `json:"server"`
+	UserID int    `json:"user_id"`
+	TaskID int    `json:"task_id"`
+}
+
+func timerPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "timer.json"), nil
+}
+
+// SavedTimer returns the ID of the task started from this machine for the
+// configured server and user, zero when there is none.
+func (c *Client) SavedTimer() (int, error) {
+	path, err := timerPath()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var t timer
+	if err := json.Unmarshal(data, &t); err != nil {
+		return 0, err
+	}
+	if t.Server != c.cfg.Server || t.UserID != c.cfg.UserID {
+		return 0, nil
+	}
+	return t.TaskID, nil
+}
+
+func (c *Client) saveTimer(taskID int) error {
+	path, err := timerPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(timer{Server: c.cfg.Server, UserID: c.cfg.UserID, TaskID: taskID})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// ClearTimer forgets the task started from this machine.
+func (c *Client) ClearTimer() error {
+	path, err := timerPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package validation registers the custom rules used in the binding tags of
+// the input models, so that the REST and gRPC APIs validate input alike.
+package validation
+
+import (
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(FieldName)
+
+	_ = v.RegisterValidation("passport", func(fl validator.FieldLevel) bool {
+		return models.PassportPattern.MatchString(fl.Field().String())
+	})
+	_ = v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	_ = v.RegisterValidation("notfuture", func(fl validator.FieldLevel) bool {
+		t, ok := fl.Field().Interface().(time.Time)
+		return ok && !t.After(time.Now())
+	})
+
+	_ = v.RegisterValidation("https_url", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		return err == nil && u.Scheme == "https" && u.Hostname() != "" && u.User == nil
+	})
+
+	_ = v.RegisterValidation("sortby", func(fl validator.FieldLevel) bool {
+		field, dir, _ := strings.Cut(fl.Field().String(), ":")
+		if dir != "" && dir != "asc" && dir != "desc" {
+			return false
+		}
+		return slices.Contains(strings.Fields(fl.Param()), field)
+	})
+
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		input := sl.Current().Interface().(models.InputTask)
+		if input.StartPeriod != nil && input.EndPeriod != nil && input.EndPeriod.Before(*input.StartPeriod) {
+			sl.ReportError(input.EndPeriod, "end_time", "EndPeriod", "afterstart", "")
+		}
+	}, models.InputTask{})
+
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		input := sl.Current().Interface().(models.InputTaskPatch)
+		if input.StartTime != nil && input.EndTime != nil && input.EndTime.Before(*input.StartTime) {
+			sl.ReportError(input.EndTime, "end_time", "EndTime", "afterstart", "")
+		}
+	}, models.InputTaskPatch{})
+}
+
+// FieldName reports fields under the name clients send them with.
+func FieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Struct runs the validation rules of an already populated input.
+func Struct(input any) error {
+	return binding.Validator.ValidateStruct(input)
+}
+
+// Message describes the failed rule to clients.
+func Message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "passport":
+		return "must match format NNNN NNNNNN"
+	case "notfuture":
+		return "must not be in the future"
+	case "sortby":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ") + ", optionally followed by :asc or :desc"
+	case "required_if", "required_unless":
+		field, value, _ := strings.Cut(fe.Param(), " ")
+		cond := "when"
+		if fe.Tag() == "required_unless" {
+			cond = "unless"
+		}
+		return "is required " + cond + " " + strings.ToLower(field) + " is " + value
+	case "afterstart":
+		return "must not be before start_time"
+	case "http_url":
+		return "must be an http or https URL"
+	case "https_url":
+		return "must be an https URL without credentials"
+	case "unique":
+		return "must not contain duplicates"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters long"
+		}
+		return "must be at most " + fe.Param()
+	}
+	return fmt.Sprintf("failed on the %q rule", fe.Tag())
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook would be sent to an
+// address that is not public.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// nonPublic are the ranges IsPrivate and IsGlobalUnicast leave out: "this
+// network" and the shared address space of carrier-grade NAT, where some
+// clouds serve their metadata.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// newTransport returns the transport of the webhooks. Anyone allowed to
+// create a webhook chooses where it is sent, so it only connects to public
+// addresses: the check is made on the address being dialed, after DNS
+// resolution, so a public name resolving to an internal address is refused
+// too. The webhooks do not go through a proxy, which would be dialed instead.
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicOnly,
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	if !isPublic(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ap.Addr())
+	}
+	return nil
+}
+
+// isPublic tells whether addr is a publicly routable unicast address, so
+// neither loopback, private, link-local, multicast nor unspecified.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.215.14", want: true},
+		{addr: "2606:2800:21f:cb07:6820:80da:af6b:8b2c", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "fd00::1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "100.100.100.200"},
+		{addr: "0.0.0.0"},
+		{addr: "0.1.2.3"},
+		{addr: "::"},
+		{addr: "224.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDispatcherRefusesLoopback(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Dispatcher{client: &http.Client{Transport: newTransport(), Timeout: time.Second}}
+
+	// a name is refused once it has been resolved to the address
+	for _, target := range []string{server.URL, "http://localhost:" + u.Port()} {
+		w := models.OutgoingWebhook{URL: target, Secret: "secret"}
+		if _, _, err := d.send(context.Background(), w); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("send to %s = %v, want ErrForbiddenAddress", target, err)
+		}
+	}
+	if called {
+		t.Error("the request reached the server")
+	}
+}
@@ -0,0 +1,232 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/3XBAT/time-tracker/internal/config"
+	"github.com/3XBAT/time-tracker/internal/domain/models"
+	"github.com/3XBAT/time-tracker/internal/metrics"
+	"github.com/3XBAT/time-tracker/internal/storage"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IDHeader        = "Webhook-Id"
+	TimestampHeader = "Webhook-Timestamp"
+	SignatureHeader = "Webhook-Signature"
+	EventHeader     = "Webhook-Event"
+
+	// maxResponseLog is how much of a response body is kept in the log.
+	maxResponseLog = 1024
+)
+
+// Outbox hands out the deliveries that are due and stores their outcome.
+type Outbox interface {
+	Claim(ctx context.Context, limit int, lockFor time.Duration) ([]models.OutgoingWebhook, error)
+	Finish(ctx context.Context, delivery models.WebhookDelivery, attempt models.WebhookAttempt) error
+}
+
+// Dispatcher sends the queued deliveries to their webhooks. Every instance
+// runs one, a delivery is claimed by a single dispatcher at a time.
+type Dispatcher struct {
+	outbox  Outbox
+	client  *http.Client
+	cfg     config.WebhooksConfig
+	metrics *metrics.Metrics
+	log     *slog.Logger
+}
+
+func NewDispatcher(s *storage.Storage, cfg config.WebhooksConfig, metrics *metrics.Metrics, log *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		outbox: s.WebhookProvider,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(newTransport()),
+			Timeout:   cfg.Timeout,
+			// a redirected POST would turn into a GET, it counts as a failure
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg:     cfg,
+		metrics: metrics,
+		log:     log,
+	}
+}
+
+// Run sends the due deliveries right away and then on every poll interval
+// until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends the due deliveries batch after batch, until fewer than a
+// full batch are left.
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	const op = "webhook.Dispatcher.dispatch"
+
+	log := d.log.With(slog.String("op", op))
+
+	for ctx.Err() == nil {
+		// an attempt that has not finished by then is given up on and the
+		// delivery is claimed again
+		claimed, err := d.outbox.Claim(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("failed to claim webhook deliveries", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, w := range claimed {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.deliver(ctx, w)
+			}()
+		}
+		wg.Wait()
+
+		if len(claimed) < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt to send the delivery and schedules the next
+// one if it fails.
+func (d *Dispatcher) deliver(ctx context.Context, w models.OutgoingWebhook) {
+	const op = "webhook.Dispatcher.deliver"
+
+	log := d.log.With(slog.String("op", op), slog.Int("webhook_id", w.WebhookID), slog.Int64("delivery_id", w.ID))
+
+	start := time.Now()
+	attempt := models.WebhookAttempt{AttemptedAt: start.UTC()}
+	status, response, err := d.send(ctx, w)
+	attempt.DurationMS = int(time.Since(start).Milliseconds())
+
+	if ctx.Err() != nil {
+		// shutting down, the claim expires and the delivery is sent again
+		return
+	}
+
+	if status != 0 {
+		attempt.StatusCode = &status
+	}
+	if response != "" {
+		attempt.Response = &response
+	}
+	if err != nil {
+		msg := err.Error()
+		attempt.Error = &msg
+	}
+
+	delivery := w.WebhookDelivery
+	delivery.NextAttemptAt = nil
+	var result string
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &attempt.AttemptedAt
+		result = models.WebhookDeliveryDelivered
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		result = models.WebhookDeliveryFailed
+	default:
+		next := time.Now().Add(d.backoff(delivery.Attempts)).UTC()
+		delivery.NextAttemptAt = &next
+		result = "retry"
+	}
+
+	d.metrics.ObserveWebhook(result, time.Since(start))
+
+	if err := d.outbox.Finish(ctx, delivery, attempt); err != nil {
+		log.Error("failed to store webhook delivery attempt", slog.String("error", err.Error()))
+		return
+	}
+
+	switch result {
+	case models.WebhookDeliveryDelivered:
+		log.Debug("webhook delivered", slog.Int("status", status))
+	case models.WebhookDeliveryFailed:
+		log.Warn("webhook delivery failed for good", slog.Int("attempts", delivery.Attempts), slog.Int("status", status))
+	default:
+		log.Info("webhook delivery will be retried", slog.Int("attempts", delivery.Attempts), slog.Int("status", status), slog.Time("next_attempt_at", *delivery.NextAttemptAt))
+	}
+}
+
+// send posts the signed payload and returns the response status and the
+// start of its body.
+func (d *Dispatcher) send(ctx context.Context, w models.OutgoingWebhook) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(w.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	id := strconv.FormatInt(w.ID, 10)
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "time-tracker-webhooks")
+	req.Header.Set(IDHeader, id)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, id, now, w.Payload))
+	req.Header.Set(EventHeader, w.EventType)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLog))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	// the log is stored as text, which can not hold every byte sequence
+	response := strings.ReplaceAll(strings.ToValidUTF8(string(body), "�"), "\x00", "")
+	return resp.StatusCode, response, nil
+}
+
+// backoff returns the wait before the attempt following the given one:
+// doubled after every attempt, capped and with up to a fifth added, so
+// that deliveries failing together spread out.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, d.cfg.MaxBackoff)
+	return wait + rand.N(wait/5+1)
+}
+
+// Sign returns the Webhook-Signature header of a delivery: the base64
+// HMAC-SHA256, keyed with the secret of the webhook, of the delivery id,
+// the Unix timestamp sent in Webhook-Timestamp and the body, joined with dots.
+func Sign(secret, id string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.%d.", id, timestamp.Unix())
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_addr_trgm_idx;
+DROP INDEX IF EXISTS users_patronymic_trgm_idx;
+DROP INDEX IF EXISTS users_surname_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS users_search_trgm_idx;
+DROP INDEX IF EXISTS users_search_fts_idx;
@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_search_fts_idx ON users
+    USING gin (to_tsvector('simple', lower(name || ' ' || surname || ' ' || patronymic || ' ' || addr)));
+
+CREATE INDEX users_search_trgm_idx ON users
+    USING gin (lower(name || ' ' || surname || ' ' || patronymic || ' ' || addr) gin_trgm_ops);
+
+CREATE INDEX users_name_trgm_idx ON users USING gin (name gin_trgm_ops);
+CREATE INDEX users_surname_trgm_idx ON users USING gin (surname gin_trgm_ops);
+CREATE INDEX users_patronymic_trgm_idx ON users USING gin (patronymic gin_trgm_ops);
+CREATE INDEX users_addr_trgm_idx ON users USING gin (addr gin_trgm_ops);
@@ -0,0 +1,8 @@
+-- Rows that are only marked as deleted would reappear without the columns.
+DELETE FROM tasks
+WHERE deleted_at IS NOT NULL
+   OR user_id IN (SELECT id FROM users WHERE deleted_at IS NOT NULL);
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE tasks DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
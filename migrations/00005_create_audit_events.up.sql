@@ -0,0 +1,30 @@
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    actor VARCHAR(128) NOT NULL,
+    request_id VARCHAR(128),
+    action VARCHAR(32) NOT NULL,
+    entity VARCHAR(32) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    before_state JSONB,
+    after_state JSONB
+);
+
+CREATE INDEX audit_events_entity_idx ON audit_events (entity, entity_id, id);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor, id);
+CREATE INDEX audit_events_occurred_at_idx ON audit_events (occurred_at);
+
+-- The audit log is append-only, recorded events can not be changed or removed.
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
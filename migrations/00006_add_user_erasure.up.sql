@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN erased_at TIMESTAMP WITH TIME ZONE;
+
+-- Erasing a user also removes their personal data from the audit log, so the
+-- state columns may be rewritten when the audit.redact setting is on for the
+-- transaction. Everything else stays append-only.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND current_setting('audit.redact', true) = 'on'
+        AND (NEW.id, NEW.occurred_at, NEW.actor, NEW.request_id, NEW.action, NEW.entity, NEW.entity_id)
+            IS NOT DISTINCT FROM
+            (OLD.id, OLD.occurred_at, OLD.actor, OLD.request_id, OLD.action, OLD.entity, OLD.entity_id)
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,14 @@
+-- Responses to requests made with an Idempotency-Key, a row without
+-- status_code belongs to a request that is still in progress.
+CREATE TABLE idempotency_keys (
+    actor VARCHAR(128) NOT NULL,
+    key VARCHAR(128) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (actor, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
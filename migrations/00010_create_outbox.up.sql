@@ -0,0 +1,17 @@
+-- The events of the changes of users and tasks, each added in the
+-- transaction of its change. The relay hands them to the sinks in order of
+-- id, published_to lists the sinks that have accepted an event and
+-- published_at is set once all of them have.
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_type VARCHAR(32) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    published_to TEXT[] NOT NULL DEFAULT '{}',
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
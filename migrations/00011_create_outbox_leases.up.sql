@@ -0,0 +1,8 @@
+-- A sink is relayed to by one instance at a time: the instance holding its
+-- lease. The lease is renewed with every batch and taken over by another
+-- instance once it has expired.
+CREATE TABLE outbox_leases (
+    sink VARCHAR(32) PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
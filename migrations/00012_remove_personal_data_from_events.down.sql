@@ -0,0 +1,4 @@
+-- The personal data removed from the events is not restored.
+DROP INDEX IF EXISTS idempotency_keys_user_id_idx;
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,15 @@
+-- Events no longer carry the personal data of users, it is removed from the
+-- events written before.
+UPDATE outbox
+SET payload = jsonb_set(payload, '{user}', (payload -> 'user') - ARRAY['passport_number', 'name', 'surname', 'patronymic', 'addr'])
+WHERE payload ? 'user';
+
+UPDATE webhook_deliveries
+SET payload = jsonb_set(payload, '{user}', (payload -> 'user') - ARRAY['passport_number', 'name', 'surname', 'patronymic', 'addr'])
+WHERE payload ? 'user';
+
+-- The user whose data a stored response carries, the response is removed
+-- when the user is erased.
+ALTER TABLE idempotency_keys ADD COLUMN user_id INTEGER;
+
+CREATE INDEX idempotency_keys_user_id_idx ON idempotency_keys (user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations into the binary, so
+// that they are available regardless of the working directory.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
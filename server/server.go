@@ -2,21 +2,22 @@ package server
 
 import (
 	"context"
+	"github.com/3XBAT/time-tracker/internal/config"
 	"net/http"
-	"time"
 )
 
 type Server struct {
 	httpServer *http.Server
 }
 
-func (s *Server) Run(port string, handler http.Handler) error {
+func (s *Server) Run(port string, handler http.Handler, cfg config.HTTPConfig) error {
 	s.httpServer = &http.Server{
 		Addr:           port,
 		Handler:        handler,
 		MaxHeaderBytes: 1 << 20,
-		ReadTimeout:    5 * time.Second,
-		WriteTimeout:   5 * time.Second,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
 	}
 
 	return s.httpServer.ListenAndServe()